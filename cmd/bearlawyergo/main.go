@@ -2,25 +2,53 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"rsandz/bearlawyergo/internal/cli"
+	"rsandz/bearlawyergo/internal/config"
+	"rsandz/bearlawyergo/internal/contextwindow"
 	"rsandz/bearlawyergo/internal/discord"
+	"rsandz/bearlawyergo/internal/fakellm"
 	llmHandler "rsandz/bearlawyergo/internal/handler/llm"
 	"rsandz/bearlawyergo/internal/handler/validation"
+	"rsandz/bearlawyergo/internal/httpapi"
 	"rsandz/bearlawyergo/internal/logging"
+	"rsandz/bearlawyergo/internal/memory"
 	"rsandz/bearlawyergo/internal/orchestrator"
+	"rsandz/bearlawyergo/internal/provider"
+	"rsandz/bearlawyergo/internal/tool"
+	"rsandz/bearlawyergo/internal/transport"
 
 	"github.com/joho/godotenv"
-	"github.com/tmc/langchaingo/llms/openai"
+	"github.com/tmc/langchaingo/llms"
+)
+
+const (
+	// defaultModel matches the model langchaingo's OpenAI client uses when OPENAI_MODEL is unset.
+	defaultModel       = "gpt-3.5-turbo"
+	defaultTokenBudget = 4000
 )
 
 func main() {
+	// Parse flags
+	useDiscord := flag.Bool("discord", false, "Run as Discord bot")
+	httpAddr := flag.String("http", "", "Address to serve the HTTP API on, e.g. :8080. The API is disabled if empty. Set HTTP_API_TOKEN to require a bearer token")
+	historyDB := flag.String("history-db", "", "Path to a SQLite database for chat history. History is kept in memory if empty")
+	llmConfigPath := flag.String("llm-config", "", "Path to a YAML file configuring the LLM provider. OpenAI is used if empty")
+	llmMode := flag.String("llm-mode", "live", "How to answer with the LLM: live, record (live, saving exchanges to -llm-cassette), replay (answers from -llm-cassette) or scripted (canned replies from -llm-cassette)")
+	llmCassette := flag.String("llm-cassette", "", "Path to the cassette for record and replay modes, or the script for scripted mode")
+	flag.Parse()
+
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found")
 	}
@@ -34,57 +62,274 @@ func main() {
 
 	ctx := context.Background()
 
-	llm, err := openai.New()
+	llmConfig, err := config.LoadLLM(*llmConfigPath)
+	if err != nil {
+		logger.Error("Failed to load LLM config", "error", err)
+		os.Exit(1)
+	}
+	llm, err := newLLM(ctx, *llmMode, *llmCassette, llmConfig, logger)
 	if err != nil {
 		logger.Error("Failed to create LLM", "error", err)
 		os.Exit(1)
 	}
 
 	validationHandler := validation.NewHandler()
-	llmHandler, err := llmHandler.NewLLMHandler(llm, logger)
+	tools, err := tool.NewRegistry(tool.NewDiceTool())
+	if err != nil {
+		logger.Error("Failed to create tool registry", "error", err)
+		os.Exit(1)
+	}
+
+	window, err := newContextWindow(llmConfig.Model, logger)
+	if err != nil {
+		logger.Error("Failed to create context window builder", "error", err)
+		os.Exit(1)
+	}
+
+	llmHandler, err := llmHandler.NewLLMHandler(llm, tools, window, logger)
 	if err != nil {
 		logger.Error("Failed to create LLM handler", "error", err)
 		os.Exit(1)
 	}
 
-	handlers := []orchestrator.Handler{
-		validationHandler,
-		llmHandler,
+	prompts, err := config.LoadPrompts()
+	if err != nil {
+		logger.Error("Failed to load prompts", "error", err)
+		os.Exit(1)
 	}
 
-	orch := orchestrator.NewOrchestrator(handlers, logger)
-	// Parse flags
-	useDiscord := flag.Bool("discord", false, "Run as Discord bot")
-	flag.Parse()
+	var fallback orchestrator.Handler
+	if prompts.FallbackReply != "" {
+		fallback = orchestrator.ReplyHandler(prompts.FallbackReply)
+	}
+	router := orchestrator.NewRouter(fallback)
+	routes := []orchestrator.Route{
+		{Name: "validation", Priority: 100, Handler: validationHandler},
+		{Name: "llm", Priority: 0, Handler: llmHandler},
+	}
+	for _, route := range routes {
+		if err := router.Register(route); err != nil {
+			logger.Error("Failed to register route", "route", route.Name, "error", err)
+			os.Exit(1)
+		}
+	}
 
-	if *useDiscord {
-		token := os.Getenv("DISCORD_TOKEN")
-		if token == "" {
-			logger.Error("DISCORD_TOKEN environment variable not set")
+	orch := orchestrator.NewOrchestrator(router, logger)
+	if raw := os.Getenv("HANDLER_TIMEOUT"); raw != "" {
+		timeout, err := time.ParseDuration(raw)
+		if err != nil {
+			logger.Error("Invalid HANDLER_TIMEOUT", "value", raw, "error", err)
 			os.Exit(1)
 		}
+		orch.SetHandlerTimeout(timeout)
+	}
+	summarizer := memory.NewLLMSummarizer(llm, prompts.SummaryPrompt)
 
-		bot, err := discord.NewBot(token, orch, logger)
+	store, err := newStore(*historyDB)
+	if err != nil {
+		logger.Error("Failed to open chat history store", "error", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	dispatcher := transport.NewDispatcher(orch, store, summarizer, logger)
+	var transports []transport.Transport
+	if *useDiscord {
+		bot, err := newDiscordBot(dispatcher, logger)
 		if err != nil {
 			logger.Error("Failed to create Discord bot", "error", err)
 			os.Exit(1)
 		}
+		transports = append(transports, bot)
+	}
+	if *httpAddr != "" {
+		server := httpapi.NewServer(*httpAddr, dispatcher, logger)
+		server.SetToken(os.Getenv("HTTP_API_TOKEN"))
+		transports = append(transports, server)
+	}
+	// The command line is used when no other transport is enabled.
+	if len(transports) == 0 {
+		transports = append(transports, cli.NewREPL(dispatcher, logger))
+	}
 
-		if err := bot.Start(); err != nil {
-			logger.Error("Failed to start Discord bot", "error", err)
-			os.Exit(1)
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := transport.Run(ctx, logger, transports...); err != nil {
+		logger.Error("Transport failed", "error", err)
+	}
+	dispatcher.Wait()
+	logger.Info("Shutting down Bear Lawyer")
+}
+
+// newDiscordBot creates the Discord bot, configured from the DISCORD_* environment variables.
+func newDiscordBot(dispatcher *transport.Dispatcher, logger *slog.Logger) (*discord.Bot, error) {
+	token := os.Getenv("DISCORD_TOKEN")
+	if token == "" {
+		return nil, errors.New("DISCORD_TOKEN environment variable not set")
+	}
+
+	bot, err := discord.NewBot(token, dispatcher, logger)
+	if err != nil {
+		return nil, err
+	}
+	if raw := os.Getenv("DISCORD_ATTACHMENT_THRESHOLD"); raw != "" {
+		threshold, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DISCORD_ATTACHMENT_THRESHOLD %q: %w", raw, err)
+		}
+		bot.SetAttachmentThreshold(threshold)
+	}
+	if raw := os.Getenv("DISCORD_THREADS"); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DISCORD_THREADS %q: %w", raw, err)
 		}
-		defer bot.Close()
+		autoArchive := discord.DefaultThreadAutoArchive
+		if raw := os.Getenv("DISCORD_THREAD_AUTO_ARCHIVE"); raw != "" {
+			autoArchive, err = strconv.Atoi(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DISCORD_THREAD_AUTO_ARCHIVE %q: %w", raw, err)
+			}
+		}
+		bot.SetThreads(enabled, autoArchive)
+	}
+	dmPolicy, err := newDMPolicy()
+	if err != nil {
+		return nil, err
+	}
+	bot.SetDMPolicy(dmPolicy)
+	if raw := os.Getenv("DISCORD_COMPONENT_TTL"); raw != "" {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DISCORD_COMPONENT_TTL %q: %w", raw, err)
+		}
+		bot.SetComponentTTL(ttl)
+	}
+	if raw := os.Getenv("DISCORD_DELETE_ORPHANED_REPLIES"); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DISCORD_DELETE_ORPHANED_REPLIES %q: %w", raw, err)
+		}
+		bot.SetDeleteOrphanedReplies(enabled)
+	}
+	if err := configureHistory(bot); err != nil {
+		return nil, err
+	}
+
+	return bot, nil
+}
+
+// newLLM creates the language model for the mode. Only live and record modes talk to the configured provider.
+func newLLM(ctx context.Context, mode string, cassette string, cfg config.LLM, logger *slog.Logger) (llms.Model, error) {
+	if mode != "live" && cassette == "" {
+		return nil, fmt.Errorf("-llm-mode=%s requires -llm-cassette", mode)
+	}
+	switch mode {
+	case "live":
+		return provider.New(ctx, cfg, logger)
+	case "record":
+		live, err := provider.New(ctx, cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Recording LLM exchanges", "cassette", cassette)
+		return fakellm.NewRecorder(live, cassette)
+	case "replay":
+		logger.Info("Replaying LLM exchanges", "cassette", cassette)
+		return fakellm.LoadReplayer(cassette)
+	case "scripted":
+		logger.Info("Using scripted LLM replies", "script", cassette)
+		return fakellm.LoadScripted(cassette)
+	default:
+		return nil, fmt.Errorf("unknown LLM mode %q", mode)
+	}
+}
+
+// newContextWindow builds the context window builder for the model and the CONTEXT_TOKEN_BUDGET environment variable.
+// An empty model falls back to the OPENAI_MODEL environment variable.
+func newContextWindow(model string, logger *slog.Logger) (*contextwindow.Builder, error) {
+	if model == "" {
+		model = os.Getenv("OPENAI_MODEL")
+	}
+	if model == "" {
+		model = defaultModel
+	}
+
+	budget := defaultTokenBudget
+	if raw := os.Getenv("CONTEXT_TOKEN_BUDGET"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CONTEXT_TOKEN_BUDGET %q: %w", raw, err)
+		}
+		budget = parsed
+	}
 
-		logger.Info("Discord bot running.")
-		fmt.Println("Running. Press CTRL-C to exit.")
-		stop := make(chan os.Signal, 1)
-		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
-		<-stop
+	counter, err := contextwindow.NewTiktokenCounter(model)
+	if err != nil {
+		return nil, err
+	}
+	return contextwindow.NewBuilder(counter, budget, logger), nil
+}
+
+// newStore opens the SQLite chat history at path, or an in-memory history if path is empty.
+func newStore(path string) (memory.Store, error) {
+	if path == "" {
+		return memory.NewInMemoryStore(memory.DefaultRetention), nil
+	}
+	return memory.NewSQLiteStore(path, memory.DefaultRetention)
+}
 
-		logger.Info("Shutting down Discord bot...")
-	} else {
-		repl := cli.NewREPL(orch, logger)
-		repl.Start(ctx)
+// newDMPolicy reads the Discord direct message policy from DISCORD_DMS, DISCORD_DM_RATE_LIMIT and DISCORD_DM_RATE_WINDOW.
+func newDMPolicy() (discord.DMPolicy, error) {
+	policy := discord.DefaultDMPolicy
+	if raw := os.Getenv("DISCORD_DMS"); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return policy, fmt.Errorf("invalid DISCORD_DMS %q: %w", raw, err)
+		}
+		policy.Enabled = enabled
+	}
+	if raw := os.Getenv("DISCORD_DM_RATE_LIMIT"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil {
+			return policy, fmt.Errorf("invalid DISCORD_DM_RATE_LIMIT %q: %w", raw, err)
+		}
+		policy.RateLimit = limit
+	}
+	if raw := os.Getenv("DISCORD_DM_RATE_WINDOW"); raw != "" {
+		window, err := time.ParseDuration(raw)
+		if err != nil {
+			return policy, fmt.Errorf("invalid DISCORD_DM_RATE_WINDOW %q: %w", raw, err)
+		}
+		policy.RateWindow = window
+	}
+	return policy, nil
+}
+
+// configureHistory sets the bot's history strategies from DISCORD_HISTORY_STRATEGY, the default for all guilds,
+// and DISCORD_GUILD_HISTORY_STRATEGIES, a comma separated list of guild_id=strategy overrides.
+func configureHistory(bot *discord.Bot) error {
+	if strategy := os.Getenv("DISCORD_HISTORY_STRATEGY"); strategy != "" {
+		resolver, err := discord.NewHistoryResolver(strategy)
+		if err != nil {
+			return fmt.Errorf("invalid DISCORD_HISTORY_STRATEGY: %w", err)
+		}
+		bot.SetHistoryResolver("", resolver)
+	}
+	raw := os.Getenv("DISCORD_GUILD_HISTORY_STRATEGIES")
+	if raw == "" {
+		return nil
+	}
+	for entry := range strings.SplitSeq(raw, ",") {
+		guildID, strategy, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || guildID == "" {
+			return fmt.Errorf("invalid DISCORD_GUILD_HISTORY_STRATEGIES entry %q, expected guild_id=strategy", entry)
+		}
+		resolver, err := discord.NewHistoryResolver(strategy)
+		if err != nil {
+			return fmt.Errorf("invalid strategy for guild %s: %w", guildID, err)
+		}
+		bot.SetHistoryResolver(guildID, resolver)
 	}
+	return nil
 }
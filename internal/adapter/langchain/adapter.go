@@ -1,6 +1,8 @@
 package langchain
 
 import (
+	"strings"
+
 	"rsandz/bearlawyergo/internal/message"
 
 	"github.com/tmc/langchaingo/llms"
@@ -31,3 +33,42 @@ func ToLLMMessages(messages []message.Message) []llms.MessageContent {
 	}
 	return result
 }
+
+// FromLLMMessage converts a LangChain message content to an internal message.
+// Text parts are joined and image URLs become attachments. Reports false for roles that have no internal
+// equivalent, such as system and tool messages.
+func FromLLMMessage(content llms.MessageContent) (message.Message, bool) {
+	var msg message.Message
+	switch content.Role {
+	case llms.ChatMessageTypeHuman, llms.ChatMessageTypeGeneric:
+		msg.Role = message.UserRole
+	case llms.ChatMessageTypeAI:
+		msg.Role = message.BotRole
+	default:
+		return msg, false
+	}
+
+	var text []string
+	for _, part := range content.Parts {
+		switch part := part.(type) {
+		case llms.TextContent:
+			text = append(text, part.Text)
+		case llms.ImageURLContent:
+			// The real type of the image is unknown until it is downloaded.
+			msg.Attachments = append(msg.Attachments, message.Attachment{Name: "image", URL: part.URL, ContentType: "image/*"})
+		}
+	}
+	msg.Content = strings.Join(text, "\n")
+	return msg, true
+}
+
+// FromLLMMessages converts LangChain message contents to internal messages, skipping those without an internal equivalent.
+func FromLLMMessages(contents []llms.MessageContent) []message.Message {
+	var result []message.Message
+	for _, content := range contents {
+		if msg, ok := FromLLMMessage(content); ok {
+			result = append(result, msg)
+		}
+	}
+	return result
+}
@@ -38,3 +38,65 @@ func TestToLLMMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestFromLLMMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    llms.MessageContent
+		expected message.Message
+		ok       bool
+	}{
+		{
+			name:     "Human Message",
+			input:    llms.TextParts(llms.ChatMessageTypeHuman, "Hello", "there"),
+			expected: message.Message{Role: message.UserRole, Content: "Hello\nthere"},
+			ok:       true,
+		},
+		{
+			name:     "AI Message",
+			input:    llms.TextParts(llms.ChatMessageTypeAI, "Hi"),
+			expected: message.Message{Role: message.BotRole, Content: "Hi"},
+			ok:       true,
+		},
+		{
+			name: "Image URL",
+			input: llms.MessageContent{
+				Role:  llms.ChatMessageTypeHuman,
+				Parts: []llms.ContentPart{llms.ImageURLContent{URL: "https://example.com/exhibit.png"}},
+			},
+			expected: message.Message{
+				Role:        message.UserRole,
+				Attachments: []message.Attachment{{Name: "image", URL: "https://example.com/exhibit.png", ContentType: "image/*"}},
+			},
+			ok: true,
+		},
+		{
+			name:  "System Message",
+			input: llms.TextParts(llms.ChatMessageTypeSystem, "Be a lawyer"),
+			ok:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := FromLLMMessage(tt.input)
+			if ok != tt.ok {
+				t.Fatalf("Expected ok %v, got %v", tt.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			if result.Role != tt.expected.Role || result.Content != tt.expected.Content {
+				t.Errorf("Expected %+v, got %+v", tt.expected, result)
+			}
+			if len(result.Attachments) != len(tt.expected.Attachments) {
+				t.Fatalf("Expected %d attachments, got %d", len(tt.expected.Attachments), len(result.Attachments))
+			}
+			for i, attachment := range result.Attachments {
+				if attachment.URL != tt.expected.Attachments[i].URL || !attachment.IsImage() {
+					t.Errorf("Expected attachment %+v, got %+v", tt.expected.Attachments[i], attachment)
+				}
+			}
+		})
+	}
+}
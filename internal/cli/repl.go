@@ -4,84 +4,191 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"strings"
 
-	"rsandz/bearlawyergo/internal/memory"
 	"rsandz/bearlawyergo/internal/message"
-	"rsandz/bearlawyergo/internal/orchestrator"
+	"rsandz/bearlawyergo/internal/transport"
 )
 
 const (
 	cliUser    = "cli-user"
-	cliBot     = "cli-bot"
 	cliChannel = "cli-channel"
 	maxHistory = 10
+
+	streamBuffer = 32
 )
 
 type REPL struct {
-	orchestrator *orchestrator.Orchestrator
+	dispatcher *transport.Dispatcher
 
 	logger *slog.Logger
 }
 
-func NewREPL(orchestrator *orchestrator.Orchestrator, logger *slog.Logger) *REPL {
+func NewREPL(dispatcher *transport.Dispatcher, logger *slog.Logger) *REPL {
 	return &REPL{
-		orchestrator: orchestrator,
-		logger:       logger,
+		dispatcher: dispatcher,
+		logger:     logger,
 	}
 }
 
-func (r *REPL) Start(ctx context.Context) {
-	reader := bufio.NewReader(os.Stdin)
-	chatHistory := memory.NewChatHistory()
-	fmt.Println("Bear Lawyer CLI started. Type 'quit' or 'exit' to leave.")
+func (r *REPL) Name() string {
+	return "cli"
+}
+
+// Start reads messages from standard input until the user quits, input ends or ctx is cancelled.
+func (r *REPL) Start(ctx context.Context) error {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			text, err := reader.ReadString('\n')
+			if text != "" {
+				lines <- text
+			}
+			if err != nil {
+				if err != io.EOF {
+					r.logger.Error("Failed to read input", "error", err)
+				}
+				return
+			}
+		}
+	}()
 
+	fmt.Println("Bear Lawyer CLI started. Type 'quit' or 'exit' to leave.")
 	for {
 		fmt.Print("> ")
-		text, _ := reader.ReadString('\n')
-		text = strings.TrimSpace(text)
+		var text string
+		select {
+		case <-ctx.Done():
+			return nil
+		case line, ok := <-lines:
+			if !ok {
+				return nil
+			}
+			text = strings.TrimSpace(line)
+		}
 
 		if text == "quit" || text == "exit" {
-			break
+			return nil
 		}
 
 		if text == "" {
 			continue
 		}
 
-		msg := &message.Message{
+		r.handle(ctx, text)
+	}
+}
+
+func (r *REPL) Stop() error {
+	return nil
+}
+
+// Send prints the message. The command line has a single conversation, so the conversation ID is ignored.
+func (r *REPL) Send(ctx context.Context, conversationID string, msg message.Message) error {
+	fmt.Printf("Bear Lawyer: %s\n", msg.Content)
+	return nil
+}
+
+func (r *REPL) Capabilities() message.Capabilities {
+	return message.Capabilities{Streaming: true}
+}
+
+// handle answers a line of input, printing the response as it is generated.
+func (r *REPL) handle(ctx context.Context, text string) {
+	history, err := r.dispatcher.History(ctx, cliChannel)
+	if err != nil {
+		fmt.Printf("Error loading chat history: %v\n", err)
+		return
+	}
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	r.logger.Info("Processing message", "message", text, "history", history)
+
+	request := &message.Request{
+		RequestMessage: message.Message{
 			Content: text,
 			User:    cliUser,
 			Role:    message.UserRole,
-		}
+		},
+		History:      history,
+		ChannelID:    cliChannel,
+		Capabilities: r.Capabilities(),
+	}
 
-		history := chatHistory.Messages()
-		r.logger.Info("Processing message", "message", text, "history", history)
+	resp, streamed, err := r.handleStreaming(ctx, request)
+	if err != nil {
+		fmt.Println(transport.ErrorReply(err))
+		return
+	}
 
-		// Add user message to history after resolve above to prevent duplicates.
-		chatHistory.Add(*msg)
+	if !streamed {
+		fmt.Printf("Bear Lawyer: %s\n", resp.ResponseMessage.Content)
+	}
+	for _, output := range resp.Outputs {
+		printOutput(output)
+	}
+}
 
-		request := &message.Request{
-			RequestMessage: *msg,
-			History:        history,
-			Channel:        cliChannel,
+// handleStreaming handles the request, printing the response as it is generated.
+// Reports whether any of the response was printed.
+func (r *REPL) handleStreaming(ctx context.Context, request *message.Request) (*message.Response, bool, error) {
+	stream := message.NewStream(streamBuffer)
+	var resp *message.Response
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		resp, err = r.dispatcher.Dispatch(ctx, request, stream)
+	}()
+
+	streamed := false
+	for chunk := range stream.Chunks() {
+		if !streamed {
+			fmt.Print("Bear Lawyer: ")
+			streamed = true
 		}
+		fmt.Print(chunk)
+	}
+	if streamed {
+		fmt.Println()
+	}
 
-		resp, err := r.orchestrator.Handle(ctx, request)
-		if err != nil {
-			fmt.Printf("Error handling message: %v\n", err)
-			continue
-		}
+	<-done
+	return resp, streamed, err
+}
 
-		if resp != nil {
-			fmt.Printf("Bear Lawyer: %s\n", resp.ResponseMessage.Content)
-			chatHistory.Add(message.Message{
-				Role:    message.BotRole,
-				Content: resp.ResponseMessage.Content,
-				User:    cliBot,
-			})
+// printOutput prints the output as plain text.
+func printOutput(output message.Output) {
+	switch output.Kind {
+	case message.TextOutput:
+		fmt.Printf("Bear Lawyer: %s\n", output.Text)
+	case message.ReactionOutput:
+		fmt.Printf("Bear Lawyer reacts with %s\n", output.Text)
+	case message.EmbedOutput:
+		embed := output.Embed
+		fmt.Printf("Bear Lawyer: %s\n", embed.Title)
+		if embed.Description != "" {
+			fmt.Println(embed.Description)
+		}
+		for _, field := range embed.Fields {
+			fmt.Printf("  %s: %s\n", field.Name, field.Value)
+		}
+		if embed.Footer != "" {
+			fmt.Println(embed.Footer)
+		}
+	case message.FileOutput:
+		if output.Text != "" {
+			fmt.Printf("Bear Lawyer: %s\n", output.Text)
+		}
+		fmt.Printf("[file %s, %d bytes]\n", output.File.Name, len(output.File.Data))
+		if strings.HasPrefix(output.File.ContentType, "text/") {
+			fmt.Println(string(output.File.Data))
 		}
 	}
 }
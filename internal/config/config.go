@@ -3,6 +3,7 @@ package config
 import (
 	_ "embed"
 	"fmt"
+	"slices"
 
 	"gopkg.in/yaml.v3"
 )
@@ -10,8 +11,20 @@ import (
 //go:embed prompts.yaml
 var promptsYAML []byte
 
+// DefaultPersona is the name of the persona that uses SystemPrompt.
+const DefaultPersona = "bear_lawyer"
+
 type Prompts struct {
 	SystemPrompt string `yaml:"system_prompt"`
+	// Personas are alternative system prompts, keyed by name, that channels can switch to.
+	Personas map[string]string `yaml:"personas"`
+	// DMPrompt is the system prompt for direct messages when no persona has been chosen.
+	// Direct messages use SystemPrompt if empty.
+	DMPrompt string `yaml:"dm_prompt"`
+	// SummaryPrompt instructs the model when condensing older conversation into a summary.
+	SummaryPrompt string `yaml:"summary_prompt"`
+	// FallbackReply is sent when no handler matches a message.
+	FallbackReply string `yaml:"fallback_reply"`
 }
 
 var promptsConfig *Prompts
@@ -29,3 +42,23 @@ func LoadPrompts() (*Prompts, error) {
 	promptsConfig = &p
 	return promptsConfig, nil
 }
+
+// PersonaPrompt returns the system prompt of the named persona.
+// An empty name selects the default persona.
+func (p *Prompts) PersonaPrompt(name string) (string, bool) {
+	if name == "" || name == DefaultPersona {
+		return p.SystemPrompt, true
+	}
+	prompt, ok := p.Personas[name]
+	return prompt, ok
+}
+
+// PersonaNames returns the names of all personas, including the default, sorted.
+func (p *Prompts) PersonaNames() []string {
+	names := []string{DefaultPersona}
+	for name := range p.Personas {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Providers of language models.
+const (
+	OpenAIProvider = "openai"
+	// OpenAICompatibleProvider is any server that implements the OpenAI API at BaseURL, such as a mock server.
+	OpenAICompatibleProvider = "openai_compatible"
+	OllamaProvider           = "ollama"
+	AnthropicProvider        = "anthropic"
+	GoogleAIProvider         = "googleai"
+)
+
+// LLM configures the language model the bot talks to.
+type LLM struct {
+	Provider string `yaml:"provider"`
+	// Model names the model. Empty uses the provider's default.
+	Model string `yaml:"model"`
+	// BaseURL overrides the provider's API address. Required for OpenAICompatibleProvider.
+	BaseURL string `yaml:"base_url"`
+	// APIKey authenticates with the provider. Empty uses the provider's environment variable, e.g. OPENAI_API_KEY.
+	APIKey string `yaml:"api_key"`
+	// Temperature is the default sampling temperature. Nil uses the provider's default.
+	Temperature *float64 `yaml:"temperature"`
+	// MaxTokens is the default limit on generated tokens. Zero uses the provider's default.
+	MaxTokens int `yaml:"max_tokens"`
+	// Timeout bounds each request to the provider, including reading a streamed response. Zero means no timeout.
+	Timeout time.Duration `yaml:"timeout"`
+	// Retry controls how failed requests are retried. Ignored on fallbacks, which share the primary's.
+	Retry Retry `yaml:"retry"`
+	// Fallbacks are tried in order once the model fails. Their own fallbacks are ignored.
+	Fallbacks []LLM `yaml:"fallbacks"`
+}
+
+// Retry controls the retrying of failed requests to a provider and when to stop sending it requests.
+type Retry struct {
+	// MaxAttempts is how many times a request is sent to a provider before failing over, including the first.
+	MaxAttempts int `yaml:"max_attempts"`
+	// BaseDelay is the wait before the first retry. Later retries wait exponentially longer, with jitter.
+	BaseDelay time.Duration `yaml:"base_delay"`
+	// MaxDelay caps the wait between retries. A provider asking to wait longer with Retry-After is failed over instead.
+	MaxDelay time.Duration `yaml:"max_delay"`
+	// FailureThreshold is how many requests in a row may fail before the provider's circuit opens
+	// and requests skip it.
+	FailureThreshold int `yaml:"failure_threshold"`
+	// Cooldown is how long the circuit stays open before a request is let through to test the provider.
+	Cooldown time.Duration `yaml:"cooldown"`
+}
+
+// DefaultRetry retries twice within a few seconds and skips a provider for half a minute after five failed requests.
+var DefaultRetry = Retry{
+	MaxAttempts:      3,
+	BaseDelay:        500 * time.Millisecond,
+	MaxDelay:         10 * time.Second,
+	FailureThreshold: 5,
+	Cooldown:         30 * time.Second,
+}
+
+// DefaultLLM uses OpenAI, configured by langchaingo's OPENAI_* environment variables.
+var DefaultLLM = LLM{Provider: OpenAIProvider, Retry: DefaultRetry}
+
+// LoadLLM reads the language model config from the YAML file at path, or returns DefaultLLM if path is empty.
+// Environment variables in the file, such as ${ANTHROPIC_API_KEY}, are expanded so that secrets can stay out of it.
+func LoadLLM(path string) (LLM, error) {
+	if path == "" {
+		return DefaultLLM, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return LLM{}, fmt.Errorf("failed to read LLM config: %w", err)
+	}
+
+	llm := DefaultLLM
+	if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(data))), &llm); err != nil {
+		return LLM{}, fmt.Errorf("failed to unmarshal LLM config: %w", err)
+	}
+	if err := llm.Validate(); err != nil {
+		return LLM{}, err
+	}
+	return llm, nil
+}
+
+// Validate reports whether the config can build a model and its fallbacks.
+func (c LLM) Validate() error {
+	if err := c.validateModel(); err != nil {
+		return err
+	}
+	if err := c.Retry.Validate(); err != nil {
+		return err
+	}
+	for i, fallback := range c.Fallbacks {
+		if err := fallback.validateModel(); err != nil {
+			return fmt.Errorf("invalid fallback %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// validateModel checks the settings of the model itself, ignoring retry and fallbacks.
+func (c LLM) validateModel() error {
+	switch c.Provider {
+	case OpenAIProvider, AnthropicProvider, GoogleAIProvider:
+	case OllamaProvider:
+		if c.Model == "" {
+			return fmt.Errorf("provider %s requires model", c.Provider)
+		}
+	case OpenAICompatibleProvider:
+		if c.BaseURL == "" {
+			return fmt.Errorf("provider %s requires base_url", c.Provider)
+		}
+	default:
+		return fmt.Errorf("unknown LLM provider %q", c.Provider)
+	}
+	if c.Temperature != nil && (*c.Temperature < 0 || *c.Temperature > 2) {
+		return fmt.Errorf("temperature %v is out of range [0, 2]", *c.Temperature)
+	}
+	if c.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must not be negative, got %d", c.MaxTokens)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative, got %s", c.Timeout)
+	}
+	return nil
+}
+
+// Validate reports whether the retry settings are usable.
+func (r Retry) Validate() error {
+	if r.MaxAttempts < 1 {
+		return fmt.Errorf("retry max_attempts must be at least 1, got %d", r.MaxAttempts)
+	}
+	if r.FailureThreshold < 1 {
+		return fmt.Errorf("retry failure_threshold must be at least 1, got %d", r.FailureThreshold)
+	}
+	if r.BaseDelay < 0 || r.MaxDelay < r.BaseDelay {
+		return fmt.Errorf("retry delays must satisfy 0 <= base_delay <= max_delay, got %s and %s", r.BaseDelay, r.MaxDelay)
+	}
+	if r.Cooldown < 0 {
+		return fmt.Errorf("retry cooldown must not be negative, got %s", r.Cooldown)
+	}
+	return nil
+}
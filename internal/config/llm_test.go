@@ -0,0 +1,74 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadLLM(t *testing.T) {
+	t.Setenv("TEST_LLM_KEY", "secret")
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("no path", func(t *testing.T) {
+		cfg, err := LoadLLM("")
+		if err != nil || cfg.Provider != OpenAIProvider {
+			t.Errorf("LoadLLM() = %+v, %v, want the default", cfg, err)
+		}
+	})
+
+	t.Run("file", func(t *testing.T) {
+		path := write("anthropic.yaml", "provider: anthropic\nmodel: claude\napi_key: ${TEST_LLM_KEY}\ntemperature: 0.5\nmax_tokens: 512\ntimeout: 30s\n")
+		cfg, err := LoadLLM(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Provider != AnthropicProvider || cfg.Model != "claude" || cfg.APIKey != "secret" ||
+			cfg.Temperature == nil || *cfg.Temperature != 0.5 || cfg.MaxTokens != 512 || cfg.Timeout != 30*time.Second {
+			t.Errorf("LoadLLM() = %+v", cfg)
+		}
+	})
+
+	t.Run("retry and fallbacks", func(t *testing.T) {
+		path := write("fallbacks.yaml", "retry:\n  max_attempts: 5\nfallbacks:\n  - provider: ollama\n    model: llama3.2\n")
+		cfg, err := LoadLLM(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Retry settings that are not given keep their defaults.
+		if cfg.Retry.MaxAttempts != 5 || cfg.Retry.Cooldown != DefaultRetry.Cooldown {
+			t.Errorf("Retry = %+v", cfg.Retry)
+		}
+		if len(cfg.Fallbacks) != 1 || cfg.Fallbacks[0].Provider != OllamaProvider {
+			t.Errorf("Fallbacks = %+v", cfg.Fallbacks)
+		}
+	})
+
+	invalid := []struct {
+		name    string
+		content string
+	}{
+		{name: "unknown provider", content: "provider: carrier-pigeon\n"},
+		{name: "compatible without base url", content: "provider: openai_compatible\n"},
+		{name: "ollama without model", content: "provider: ollama\n"},
+		{name: "temperature out of range", content: "temperature: 3\n"},
+		{name: "no attempts", content: "retry:\n  max_attempts: 0\n"},
+		{name: "invalid fallback", content: "fallbacks:\n  - provider: ollama\n"},
+		{name: "malformed", content: "provider: [\n"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadLLM(write("invalid.yaml", tt.content)); err == nil {
+				t.Error("LoadLLM() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package contextwindow
+
+import (
+	"context"
+	"log/slog"
+	"rsandz/bearlawyergo/internal/adapter/langchain"
+	"rsandz/bearlawyergo/internal/message"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+const (
+	// tokensPerMessage approximates the formatting overhead the chat format adds to every message.
+	tokensPerMessage = 4
+	summaryPrefix    = "Summary of the earlier conversation:\n"
+)
+
+// Builder assembles the messages sent to the model within a token budget.
+type Builder struct {
+	counter TokenCounter
+	budget  int
+	logger  *slog.Logger
+}
+
+// NewBuilder creates a new Builder.
+// A budget of zero or less disables trimming.
+func NewBuilder(counter TokenCounter, budget int, logger *slog.Logger) *Builder {
+	return &Builder{
+		counter: counter,
+		budget:  budget,
+		logger:  logger,
+	}
+}
+
+// Build returns the system prompt, the conversation summary, as much recent history as fits in the budget,
+// and the latest message.
+// The system prompt, summary and latest message are always kept, even if they alone exceed the budget.
+// The oldest history is dropped first.
+func (b *Builder) Build(ctx context.Context, systemPrompt string, summary string, history []message.Message, latest message.Message) []llms.MessageContent {
+	systemTokens := b.count(systemPrompt)
+	summaryTokens := 0
+	if summary != "" {
+		summary = summaryPrefix + summary
+		summaryTokens = b.count(summary)
+	}
+	latestTokens := b.count(latest.Content)
+	remaining := b.budget - systemTokens - summaryTokens - latestTokens
+
+	// Walk backwards from the newest message so that the most recent history is kept.
+	start := len(history)
+	historyTokens := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		tokens := b.count(history[i].Content)
+		if b.budget > 0 && historyTokens+tokens > remaining {
+			break
+		}
+		historyTokens += tokens
+		start = i
+	}
+	kept := history[start:]
+
+	b.logger.InfoContext(ctx, "Built context window",
+		"system_tokens", systemTokens,
+		"summary_tokens", summaryTokens,
+		"history_tokens", historyTokens,
+		"latest_tokens", latestTokens,
+		"total_tokens", systemTokens+summaryTokens+historyTokens+latestTokens,
+		"budget", b.budget,
+		"history_kept", len(kept),
+		"history_dropped", start,
+	)
+
+	messages := []llms.MessageContent{
+		{
+			Role:  llms.ChatMessageTypeSystem,
+			Parts: []llms.ContentPart{llms.TextContent{Text: systemPrompt}},
+		},
+	}
+	if summary != "" {
+		messages = append(messages, llms.MessageContent{
+			Role:  llms.ChatMessageTypeSystem,
+			Parts: []llms.ContentPart{llms.TextContent{Text: summary}},
+		})
+	}
+	messages = append(messages, langchain.ToLLMMessages(kept)...)
+	messages = append(messages, llms.MessageContent{
+		Role:  llms.ChatMessageTypeHuman,
+		Parts: []llms.ContentPart{llms.TextContent{Text: latest.Content}},
+	})
+	return messages
+}
+
+func (b *Builder) count(text string) int {
+	return b.counter.CountTokens(text) + tokensPerMessage
+}
@@ -0,0 +1,102 @@
+package contextwindow
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"rsandz/bearlawyergo/internal/message"
+	"strings"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// wordCounter counts one token per word.
+type wordCounter struct{}
+
+func (wordCounter) CountTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+func TestBuilder_Build(t *testing.T) {
+	history := []message.Message{
+		{Role: message.UserRole, Content: "one two three"},
+		{Role: message.BotRole, Content: "four five"},
+		{Role: message.UserRole, Content: "six"},
+	}
+	latest := message.Message{Role: message.UserRole, Content: "latest"}
+
+	tests := []struct {
+		name            string
+		budget          int
+		expectedHistory []string
+	}{
+		{name: "Unlimited", budget: 0, expectedHistory: []string{"one two three", "four five", "six"}},
+		{name: "Fits all", budget: 100, expectedHistory: []string{"one two three", "four five", "six"}},
+		// system (1+4) + latest (1+4) + six (1+4) + four five (2+4) = 21
+		{name: "Drops oldest", budget: 21, expectedHistory: []string{"four five", "six"}},
+		{name: "Keeps system and latest when over budget", budget: 1, expectedHistory: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			b := NewBuilder(wordCounter{}, tt.budget, logger)
+
+			messages := b.Build(context.Background(), "system", "", history, latest)
+
+			if messages[0].Role != llms.ChatMessageTypeSystem {
+				t.Errorf("expected first message to be the system prompt, got %s", messages[0].Role)
+			}
+			last := messages[len(messages)-1]
+			if last.Parts[0].(llms.TextContent).Text != "latest" {
+				t.Errorf("expected last message to be the latest message, got %v", last.Parts[0])
+			}
+
+			var kept []string
+			for _, m := range messages[1 : len(messages)-1] {
+				kept = append(kept, m.Parts[0].(llms.TextContent).Text)
+			}
+			if strings.Join(kept, "|") != strings.Join(tt.expectedHistory, "|") {
+				t.Errorf("expected history %v, got %v", tt.expectedHistory, kept)
+			}
+		})
+	}
+}
+
+func TestTiktokenCounter(t *testing.T) {
+	counter, err := NewTiktokenCounter("gpt-3.5-turbo")
+	if err != nil {
+		t.Fatalf("NewTiktokenCounter() unexpected error = %v", err)
+	}
+	if got := counter.CountTokens("hello world"); got != 2 {
+		t.Errorf("expected 2 tokens, got %d", got)
+	}
+
+	if _, err := NewTiktokenCounter("some-local-model"); err != nil {
+		t.Errorf("expected unknown models to fall back, got %v", err)
+	}
+}
+
+func TestBuilder_Build_Summary(t *testing.T) {
+	history := []message.Message{
+		{Role: message.UserRole, Content: "old"},
+		{Role: message.UserRole, Content: "recent"},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	// system (1+4) + prefixed summary (8+4) + latest (1+4) + recent (1+4) = 27
+	b := NewBuilder(wordCounter{}, 27, logger)
+
+	messages := b.Build(context.Background(), "system", "we discussed bears", history, message.Message{Content: "latest"})
+
+	if len(messages) != 4 {
+		t.Fatalf("expected system, summary, one history and latest message, got %d messages", len(messages))
+	}
+	summary := messages[1]
+	if summary.Role != llms.ChatMessageTypeSystem || !strings.Contains(summary.Parts[0].(llms.TextContent).Text, "we discussed bears") {
+		t.Errorf("expected summary as second system message, got %v", summary)
+	}
+	if messages[2].Parts[0].(llms.TextContent).Text != "recent" {
+		t.Errorf("expected most recent history to be kept, got %v", messages[2].Parts[0])
+	}
+}
@@ -0,0 +1,54 @@
+package contextwindow
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// fallbackEncoding is used for models tiktoken does not know, such as non-OpenAI models.
+const fallbackEncoding = "cl100k_base"
+
+var setLoaderOnce sync.Once
+
+// TokenCounter counts the tokens a model sees for a piece of text.
+type TokenCounter interface {
+	CountTokens(text string) int
+}
+
+// TiktokenCounter counts tokens with the tiktoken encoding of a model.
+type TiktokenCounter struct {
+	encoding *tiktoken.Tiktoken
+}
+
+// NewTiktokenCounter creates a counter for the given model.
+// Encodings are loaded from data embedded in the binary, so no network access is needed.
+// Unknown models fall back to the cl100k_base encoding.
+func NewTiktokenCounter(model string) (*TiktokenCounter, error) {
+	setLoaderOnce.Do(func() {
+		tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+	})
+
+	encoding, err := tiktoken.EncodingForModel(model)
+	if err != nil {
+		encoding, err = tiktoken.GetEncoding(fallbackEncoding)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load token encoding: %w", err)
+		}
+	}
+	return &TiktokenCounter{encoding: encoding}, nil
+}
+
+func (c *TiktokenCounter) CountTokens(text string) int {
+	return len(c.encoding.EncodeOrdinary(text))
+}
+
+// ApproximateCounter estimates tokens as one per four characters.
+// Useful when the model's encoding is unknown or exact counts do not matter.
+type ApproximateCounter struct{}
+
+func (ApproximateCounter) CountTokens(text string) int {
+	return len([]rune(text)) / 4
+}
@@ -0,0 +1,45 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"rsandz/bearlawyergo/internal/message"
+
+	discordgo "github.com/bwmarrin/discordgo"
+)
+
+// toAttachments converts the files attached to a Discord message.
+func toAttachments(attachments []*discordgo.MessageAttachment) []message.Attachment {
+	var result []message.Attachment
+	for _, a := range attachments {
+		result = append(result, message.Attachment{
+			Name:        a.Filename,
+			URL:         a.URL,
+			ContentType: a.ContentType,
+			Size:        a.Size,
+			Load:        downloader(a.URL, a.Size),
+		})
+	}
+	return result
+}
+
+// downloader returns a loader that downloads the file at url, reading no more than size bytes.
+func downloader(url string, size int) func(ctx context.Context) ([]byte, error) {
+	return func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download attachment: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to download attachment: %s", resp.Status)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, int64(size)))
+	}
+}
@@ -2,42 +2,174 @@ package discord
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"rsandz/bearlawyergo/internal/config"
 	"rsandz/bearlawyergo/internal/message"
 	"rsandz/bearlawyergo/internal/orchestrator"
+	"rsandz/bearlawyergo/internal/transport"
 	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	discordgo "github.com/bwmarrin/discordgo"
 )
 
+// errRateLimited is returned when a request is refused because its sender is rate limited.
+var errRateLimited = errors.New("rate limited")
+
+// errEmptyMessage is returned for messages with no content, which Discord rejects.
+var errEmptyMessage = errors.New("message is empty")
+
+const (
+	streamBuffer = 32
+	// streamEditInterval limits how often a streamed reply is edited to stay within Discord rate limits.
+	streamEditInterval = time.Second
+)
+
 type Bot struct {
-	discord      *discordgo.Session
-	orchestrator *orchestrator.Orchestrator
+	discord    *discordgo.Session
+	dispatcher *transport.Dispatcher
+
+	prompts  *config.Prompts
+	commands []command
+	// attachmentThreshold is the reply length past which the reply is sent as a file.
+	attachmentThreshold int
+	// threads enables starting a thread for each new conversation in a channel.
+	threads           bool
+	threadAutoArchive int
+	// dmPolicy decides which direct messages are answered and how often.
+	dmPolicy  DMPolicy
+	dmLimiter *rateLimiter
+	// historyResolver chooses the history of each request, unless its guild has its own in guildHistory.
+	historyMu       sync.RWMutex
+	historyResolver HistoryResolver
+	guildHistory    map[string]HistoryResolver
+	// components remembers the buttons and select menus sent with replies.
+	components *componentRegistry
+	// replies remembers the reply to each request message so that edits and deletions can follow it.
+	replies               *replyTracker
+	deleteOrphanedReplies bool
+	// personas holds the persona chosen for each conversation with /persona.
+	personasMu sync.Mutex
+	personas   map[string]string
+	// resets holds the ID of the latest /reset in each conversation. Earlier Discord messages are left out of its history.
+	resetsMu sync.Mutex
+	resets   map[string]string
 
 	logger *slog.Logger
 }
 
-func NewBot(token string, orchestrator *orchestrator.Orchestrator, logger *slog.Logger) (*Bot, error) {
+func NewBot(token string, dispatcher *transport.Dispatcher, logger *slog.Logger) (*Bot, error) {
+	prompts, err := config.LoadPrompts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompts: %w", err)
+	}
+
 	discord, err := discordgo.New("Bot " + token)
 	if err != nil {
 		return nil, err
 	}
-	bot := &Bot{discord: discord, orchestrator: orchestrator, logger: logger}
+	bot := &Bot{
+		discord:      discord,
+		dispatcher:   dispatcher,
+		prompts:      prompts,
+		personas:     make(map[string]string),
+		resets:       make(map[string]string),
+		guildHistory: make(map[string]HistoryResolver),
+		components:   newComponentRegistry(DefaultComponentTTL),
+		replies:      newReplyTracker(DefaultReplyTrackingSize, DefaultReplyTrackingTTL),
+		logger:       logger,
+
+		attachmentThreshold: DefaultAttachmentThreshold,
+		threadAutoArchive:   DefaultThreadAutoArchive,
+		historyResolver:     &LastNResolver{Limit: DefaultHistoryLimit},
+	}
+	bot.SetDMPolicy(DefaultDMPolicy)
+	bot.commands = bot.commandList()
 	discord.AddHandler(bot.handleMessage)
+	discord.AddHandler(bot.handleInteraction)
+	discord.AddHandler(bot.handleMessageUpdate)
+	discord.AddHandler(bot.handleMessageDelete)
 
 	return bot, nil
 }
 
-func (b *Bot) Start() error {
-	return b.discord.Open()
+// SetAttachmentThreshold sets the reply length past which replies are sent as a file instead of split messages.
+func (b *Bot) SetAttachmentThreshold(threshold int) {
+	b.attachmentThreshold = threshold
 }
 
-func (b *Bot) Close() error {
+// SetThreads enables starting a thread for each new conversation. Threads are archived after
+// autoArchive minutes without activity.
+func (b *Bot) SetThreads(enabled bool, autoArchive int) {
+	b.threads = enabled
+	b.threadAutoArchive = autoArchive
+}
+
+// SetHistoryResolver sets how history is resolved for requests in the guild.
+// An empty guild ID sets the resolver used by guilds without their own and by direct messages.
+func (b *Bot) SetHistoryResolver(guildID string, resolver HistoryResolver) {
+	b.historyMu.Lock()
+	defer b.historyMu.Unlock()
+	if guildID == "" {
+		b.historyResolver = resolver
+		return
+	}
+	b.guildHistory[guildID] = resolver
+}
+
+// SetComponentTTL sets how long buttons and select menus keep working after they are sent.
+func (b *Bot) SetComponentTTL(ttl time.Duration) {
+	b.components = newComponentRegistry(ttl)
+}
+
+func (b *Bot) Name() string {
+	return "discord"
+}
+
+// Start connects to Discord and answers messages until ctx is cancelled.
+func (b *Bot) Start(ctx context.Context) error {
+	if err := b.discord.Open(); err != nil {
+		return err
+	}
+	if err := b.registerCommands(); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (b *Bot) Stop() error {
 	return b.discord.Close()
 }
 
+// Send writes the message to the Discord channel or thread with the conversation's ID.
+func (b *Bot) Send(ctx context.Context, conversationID string, msg message.Message) error {
+	if strings.TrimSpace(msg.Content) == "" {
+		return errEmptyMessage
+	}
+	if _, err := b.write(&channelTarget{session: b.discord, channelID: conversationID}, nil, msg.Content); err != nil {
+		return fmt.Errorf("failed to send message to channel %s: %w", conversationID, err)
+	}
+	return nil
+}
+
+func (b *Bot) Capabilities() message.Capabilities {
+	return message.Capabilities{
+		Streaming:  true,
+		Reactions:  true,
+		Embeds:     true,
+		Files:      true,
+		Components: true,
+		Ephemeral:  true,
+	}
+}
+
 func (b *Bot) handleMessage(session *discordgo.Session, m *discordgo.MessageCreate) {
-	ctx := context.Background()
+	ctx := orchestrator.WithTraceID(context.Background(), b.logger)
 
 	if !b.shouldRespond(m.Message) {
 		return
@@ -45,23 +177,176 @@ func (b *Bot) handleMessage(session *discordgo.Session, m *discordgo.MessageCrea
 
 	b.logger.Info("Responding to Discord message", "user", m.Author.ID, "user_name", m.Author.Username, "content", m.Content)
 
-	session.ChannelTyping(m.ChannelID)
+	req := b.newRequest(ctx, m.Message)
+	if b.threads && req.ThreadID == "" && m.GuildID != "" {
+		thread, err := b.startThread(m.Message, req.RequestMessage.Content)
+		if err != nil {
+			b.logger.WarnContext(ctx, "Failed to start thread, replying in channel", "error", err, "channel_id", m.ChannelID)
+		} else {
+			req.ThreadID = thread.ID
+			req.ParentID = m.ChannelID
+		}
+	}
+	replyChannel := req.ConversationID()
+
+	session.ChannelTyping(replyChannel)
+
+	// Replying to the message makes the exchange form a reply chain.
+	target := &channelTarget{session: session, channelID: replyChannel, request: m.Reference(), requesterID: m.Author.ID}
+	b.respond(ctx, req, HistoryRequest{Message: m.Message, RequesterID: m.Author.ID}, target)
+	b.track(m.ID, target)
+}
+
+// newRequest builds a located request for the Discord message.
+func (b *Bot) newRequest(ctx context.Context, m *discordgo.Message) *message.Request {
+	msg := message.NewMessage(m.Author.Username, b.normalizeMessage(m.GuildID, m), message.UserRole)
+	msg.Timestamp = m.Timestamp
+	msg.Attachments = toAttachments(m.Attachments)
+	if m.Member != nil {
+		msg.Roles = m.Member.Roles
+	}
+
+	req := message.NewRequest(*msg, nil, m.ChannelID)
+	req.RawContent = m.Content
+	b.locate(ctx, req, m.ChannelID)
+	return req
+}
+
+// respond dispatches the located request and writes the reply to the target.
+// History and persona are scoped to the request's conversation, which is its thread if it has one.
+// Errors are reported to the user before being returned.
+func (b *Bot) respond(ctx context.Context, req *message.Request, historyReq HistoryRequest, target replyTarget) (*message.Response, error) {
+	ctx = orchestrator.WithTraceID(ctx, b.logger)
+	channelID := req.ConversationID()
+	if req.DirectMessage && !b.allowDM(ctx, channelID, target) {
+		return nil, errRateLimited
+	}
+	b.prepare(ctx, req, historyReq)
 
-	history := b.resolveHistory(m.ChannelID)
-	msg := message.NewMessage(m.Author.Username, m.Content, message.UserRole)
-	req := message.NewRequest(
-		*msg,
-		history,
-		m.ChannelID,
-	)
+	stream := message.NewStream(streamBuffer)
+	var resp *message.Response
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		resp, err = b.dispatcher.Dispatch(ctx, req, stream)
+	}()
 
-	resp, err := b.orchestrator.Handle(ctx, req)
+	reply := b.streamReply(ctx, channelID, stream, target)
+	<-done
 	if err != nil {
-		b.handleError(ctx, m.ChannelID, err)
-		return
+		b.handleError(ctx, channelID, target, reply, err)
+		return nil, err
 	}
 
-	b.discord.ChannelMessageSend(m.ChannelID, resp.ResponseMessage.Content)
+	b.reply(ctx, target, reply, resp)
+	return resp, nil
+}
+
+// prepare fills in the request's history, persona and capabilities for its conversation.
+// The channel and bot of the history request are filled in by prepare.
+func (b *Bot) prepare(ctx context.Context, req *message.Request, historyReq HistoryRequest) {
+	channelID := req.ConversationID()
+	historyReq.ChannelID = channelID
+	historyReq.BotID = b.discord.State.User.ID
+	historyReq.Normalize = func(m *discordgo.Message) string {
+		return b.normalizeMessage(req.GuildID, m)
+	}
+	historyReq.After = b.lastReset(channelID)
+	history, err := b.historyResolverFor(req.GuildID).Resolve(ctx, b.discord, historyReq)
+	if err != nil {
+		b.logger.WarnContext(ctx, "Failed to resolve history", "error", err, "channel_id", channelID)
+	}
+	req.History = history
+	req.Persona = b.persona(channelID, req.ParentID)
+	req.Capabilities = b.Capabilities()
+}
+
+// reply writes the response message, its outputs and its components to the target.
+// streamed is the message the response was streamed into, or nil if nothing was streamed.
+func (b *Bot) reply(ctx context.Context, target replyTarget, streamed *discordgo.Message, resp *message.Response) {
+	last := b.deliver(ctx, target, streamed, resp.ResponseMessage.Content)
+	if len(resp.Components) > 0 {
+		b.attachComponents(ctx, target, last, resp.Components)
+	}
+	b.render(ctx, target, resp.Outputs)
+}
+
+// streamReply progressively writes the stream into a single Discord message until the stream closes.
+// Edits are rate-limited to one per streamEditInterval. Content past Discord's message limit is truncated
+// until the complete reply is delivered.
+// Returns the message that was written, or nil if nothing was streamed.
+func (b *Bot) streamReply(ctx context.Context, channelID string, stream *message.Stream, target replyTarget) *discordgo.Message {
+	ticker := time.NewTicker(streamEditInterval)
+	defer ticker.Stop()
+
+	var reply *discordgo.Message
+	var content strings.Builder
+	dirty := false
+	for {
+		select {
+		case chunk, ok := <-stream.Chunks():
+			if !ok {
+				return reply
+			}
+			content.WriteString(chunk)
+			dirty = true
+			if reply == nil && strings.TrimSpace(content.String()) != "" {
+				sent, err := target.send(truncate(content.String(), maxMessageLength))
+				if err != nil {
+					b.logger.WarnContext(ctx, "Failed to send streamed reply", "error", err, "channel_id", channelID)
+					continue
+				}
+				reply = sent
+				dirty = false
+			}
+		case <-ticker.C:
+			if reply == nil || !dirty {
+				continue
+			}
+			edited, err := target.edit(reply, truncate(content.String(), maxMessageLength))
+			if err != nil {
+				b.logger.WarnContext(ctx, "Failed to edit streamed reply", "error", err, "channel_id", channelID)
+				continue
+			}
+			reply = edited
+			dirty = false
+		}
+	}
+}
+
+// persona returns the persona chosen for the conversation, or an empty string if none was chosen.
+// Threads without their own persona inherit their parent channel's.
+func (b *Bot) persona(conversationID, parentID string) string {
+	b.personasMu.Lock()
+	defer b.personasMu.Unlock()
+
+	if persona, ok := b.personas[conversationID]; ok {
+		return persona
+	}
+	if persona, ok := b.personas[parentID]; ok && parentID != "" {
+		return persona
+	}
+	return ""
+}
+
+func (b *Bot) setPersona(channelID string, persona string) {
+	b.personasMu.Lock()
+	defer b.personasMu.Unlock()
+	b.personas[channelID] = persona
+}
+
+// lastReset returns the ID of the latest /reset in the conversation, or an empty string if it was never reset.
+func (b *Bot) lastReset(conversationID string) string {
+	b.resetsMu.Lock()
+	defer b.resetsMu.Unlock()
+	return b.resets[conversationID]
+}
+
+func (b *Bot) setReset(conversationID string, interactionID string) {
+	b.resetsMu.Lock()
+	defer b.resetsMu.Unlock()
+	b.resets[conversationID] = interactionID
 }
 
 func (b *Bot) shouldRespond(m *discordgo.Message) bool {
@@ -69,28 +354,39 @@ func (b *Bot) shouldRespond(m *discordgo.Message) bool {
 		b.logger.Debug("Received message from self", "content", m.Content)
 		return false
 	}
-	return slices.ContainsFunc(m.Mentions, func(mention *discordgo.User) bool {
+	// Direct messages carry no guild ID.
+	if m.GuildID == "" && b.dmPolicy.Enabled {
+		return true
+	}
+	mentioned := slices.ContainsFunc(m.Mentions, func(mention *discordgo.User) bool {
 		return mention.ID == b.discord.State.User.ID
 	})
+	// Threads the bot started are its conversations, so every message in them is addressed to it.
+	return mentioned || b.ownsThread(m.ChannelID)
 }
 
-func (b *Bot) resolveHistory(channelID string) []message.Message {
-	var history []message.Message
-	messages, _ := b.discord.ChannelMessages(channelID, 10, "", "", "")
-
-	// Discord returns messages from newest to oldest. Iterate backwards to keep chronological order.
-	for i := len(messages) - 1; i >= 0; i-- {
-		dm := messages[i]
-		role := message.UserRole
-		if dm.Author.ID == b.discord.State.User.ID {
-			role = message.BotRole
-		}
-		history = append(history, *message.NewMessage(dm.Author.Username, dm.Content, role))
+// historyResolverFor returns the history resolver configured for the guild.
+func (b *Bot) historyResolverFor(guildID string) HistoryResolver {
+	b.historyMu.RLock()
+	defer b.historyMu.RUnlock()
+	if resolver, ok := b.guildHistory[guildID]; ok {
+		return resolver
 	}
-	return history
+	return b.historyResolver
 }
 
-func (b *Bot) handleError(ctx context.Context, channelId string, err error) {
-	b.discord.ChannelMessageSend(channelId, "Sorry! Something went wrong. Please try again later.")
-	b.logger.InfoContext(ctx, "error handling message", "error", err, "channel_id", channelId)
+// handleError tells the user their request failed.
+// streamed is the message a partial reply was streamed into, or nil. It is edited into the error message,
+// so the partial reply is not left behind.
+func (b *Bot) handleError(ctx context.Context, channelId string, target replyTarget, streamed *discordgo.Message, err error) {
+	if streamed != nil {
+		_, editErr := target.edit(streamed, transport.ErrorReply(err))
+		if editErr == nil {
+			return
+		}
+		b.logger.WarnContext(ctx, "Failed to replace streamed reply with error reply", "error", editErr, "channel_id", channelId)
+	}
+	if _, sendErr := target.send(transport.ErrorReply(err)); sendErr != nil {
+		b.logger.ErrorContext(ctx, "Failed to send error reply", "error", sendErr, "channel_id", channelId)
+	}
 }
@@ -0,0 +1,114 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"rsandz/bearlawyergo/internal/orchestrator"
+	"rsandz/bearlawyergo/internal/transport"
+
+	discordgo "github.com/bwmarrin/discordgo"
+)
+
+// fakeTarget records the messages written to it.
+type fakeTarget struct {
+	sent    []string
+	edited  map[string]string
+	sendErr error
+	editErr error
+}
+
+func (t *fakeTarget) send(content string) (*discordgo.Message, error) {
+	if t.sendErr != nil {
+		return nil, t.sendErr
+	}
+	t.sent = append(t.sent, content)
+	return &discordgo.Message{ID: "sent", Content: content}, nil
+}
+
+func (t *fakeTarget) edit(msg *discordgo.Message, content string) (*discordgo.Message, error) {
+	if t.editErr != nil {
+		return nil, t.editErr
+	}
+	if t.edited == nil {
+		t.edited = make(map[string]string)
+	}
+	t.edited[msg.ID] = content
+	return &discordgo.Message{ID: msg.ID, Content: content}, nil
+}
+
+func (t *fakeTarget) attach(msg *discordgo.Message, content string, file *discordgo.File) (*discordgo.Message, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (t *fakeTarget) sendOutput(data *discordgo.MessageSend, reply, ephemeral bool) (*discordgo.Message, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (t *fakeTarget) react(emoji string) error { return errors.ErrUnsupported }
+
+func (t *fakeTarget) setComponents(msg *discordgo.Message, components []discordgo.MessageComponent) (*discordgo.Message, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func TestHandleError(t *testing.T) {
+	b := &Bot{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	handlerErr := &orchestrator.HandlerError{Err: orchestrator.ErrHandlerTimeout}
+	want := transport.ErrorReply(handlerErr)
+	partial := &discordgo.Message{ID: "partial", Content: "Order in"}
+
+	t.Run("nothing streamed", func(t *testing.T) {
+		target := &fakeTarget{}
+		b.handleError(context.Background(), "channel", target, nil, handlerErr)
+		if len(target.sent) != 1 || target.sent[0] != want {
+			t.Errorf("expected error reply to be sent, got %q", target.sent)
+		}
+	})
+
+	t.Run("partial reply streamed", func(t *testing.T) {
+		target := &fakeTarget{}
+		b.handleError(context.Background(), "channel", target, partial, handlerErr)
+		if target.edited["partial"] != want || len(target.sent) != 0 {
+			t.Errorf("expected partial reply to be replaced by the error reply, got edits %q and sends %q", target.edited, target.sent)
+		}
+	})
+
+	t.Run("partial reply cannot be edited", func(t *testing.T) {
+		target := &fakeTarget{editErr: errors.New("unknown message")}
+		b.handleError(context.Background(), "channel", target, partial, handlerErr)
+		if len(target.sent) != 1 || target.sent[0] != want {
+			t.Errorf("expected error reply to be sent, got %q", target.sent)
+		}
+	})
+}
+
+func TestWrite(t *testing.T) {
+	b := &Bot{attachmentThreshold: DefaultAttachmentThreshold}
+	sendErr := errors.New("missing access")
+
+	t.Run("sent", func(t *testing.T) {
+		target := &fakeTarget{}
+		last, err := b.write(target, nil, "Order in the court")
+		if err != nil || last == nil || len(target.sent) != 1 {
+			t.Errorf("expected message to be sent, got %v, %v", last, err)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		target := &fakeTarget{}
+		last, err := b.write(target, nil, "  \n")
+		if err != nil || last != nil || len(target.sent) != 0 {
+			t.Errorf("expected nothing to be sent without an error, got %v, %v", last, err)
+		}
+	})
+
+	t.Run("send fails", func(t *testing.T) {
+		_, err := b.write(&fakeTarget{sendErr: sendErr}, nil, "Order in the court")
+		if !errors.Is(err, sendErr) {
+			t.Errorf("expected the send error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package discord
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxMessageLength is the most characters Discord accepts in one message.
+	maxMessageLength = 2000
+	// DefaultAttachmentThreshold is the reply length past which the reply is sent as a file instead of many messages.
+	DefaultAttachmentThreshold = 3 * maxMessageLength
+
+	codeFence = "```"
+)
+
+// splitMessage splits content into chunks of at most limit bytes.
+// Splits prefer paragraph breaks, then code fences, line breaks, sentence ends and spaces.
+// A code block cut by a split is closed at the end of the chunk and reopened, with its language, at the start of the next.
+// Lengths are measured in bytes, which never undercounts Discord's character limit.
+func splitMessage(content string, limit int) []string {
+	var chunks []string
+	reopen := ""
+	for content != "" {
+		budget := limit - len(reopen)
+		if len(content) <= budget {
+			chunks = append(chunks, reopen+content)
+			break
+		}
+
+		cut := splitPoint(content, budget)
+		chunk := reopen + strings.TrimRight(content[:cut], " \n")
+		open, lang := openCodeBlock(chunk)
+		if open {
+			// Leave room to close the code block left open by the split.
+			cut = splitPoint(content, budget-len("\n"+codeFence))
+			chunk = reopen + strings.TrimRight(content[:cut], " \n")
+			open, lang = openCodeBlock(chunk)
+		}
+		content = content[cut:]
+
+		if open {
+			chunk += "\n" + codeFence
+			reopen = codeFence + lang + "\n"
+			// Keep leading spaces, which may be code indentation.
+			content = strings.TrimLeft(content, "\n")
+		} else {
+			reopen = ""
+			content = strings.TrimLeft(content, " \n")
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// splitPoint returns the index at which to cut s so that the first part is at most size bytes.
+func splitPoint(s string, size int) int {
+	size = min(len(s), max(1, size))
+	window := s[:size]
+	separators := []string{"\n\n", "\n" + codeFence, "\n", ". ", "! ", "? ", " "}
+	for _, separator := range separators {
+		if i := strings.LastIndex(window, separator); i > 0 {
+			if separator == "\n"+codeFence {
+				// Cut before the fence so that it starts the next chunk.
+				return i + 1
+			}
+			return i + len(separator)
+		}
+	}
+
+	// No natural boundary; cut at the last whole character.
+	cut := size
+	for cut > 0 && cut < len(s) && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	if cut == 0 {
+		_, width := utf8.DecodeRuneInString(s)
+		return width
+	}
+	return cut
+}
+
+// openCodeBlock reports whether s ends inside a fenced code block, and the language of that block.
+func openCodeBlock(s string) (bool, string) {
+	open := false
+	lang := ""
+	for line := range strings.Lines(s) {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, codeFence) {
+			continue
+		}
+		if open {
+			open = false
+			lang = ""
+		} else {
+			open = true
+			lang = strings.TrimSpace(strings.TrimPrefix(trimmed, codeFence))
+		}
+	}
+	return open, lang
+}
+
+// truncate shortens s to at most limit bytes, marking the cut with an ellipsis.
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	const ellipsis = "…"
+	cut := limit - len(ellipsis)
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + ellipsis
+}
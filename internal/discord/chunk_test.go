@@ -0,0 +1,83 @@
+package discord
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		limit    int
+		expected []string
+	}{
+		{
+			name:     "Fits",
+			content:  "Order is restored.",
+			limit:    100,
+			expected: []string{"Order is restored."},
+		},
+		{
+			name:     "Paragraphs",
+			content:  "First paragraph here.\n\nSecond paragraph here.",
+			limit:    30,
+			expected: []string{"First paragraph here.", "Second paragraph here."},
+		},
+		{
+			name:     "Sentences",
+			content:  "One sentence. Two sentence. Three.",
+			limit:    16,
+			expected: []string{"One sentence.", "Two sentence.", "Three."},
+		},
+		{
+			name:     "Hard cut",
+			content:  "abcdefghijkl",
+			limit:    5,
+			expected: []string{"abcde", "fghij", "kl"},
+		},
+		{
+			name:     "Code block reopened",
+			content:  "```go\nline one\nline two\nline three\n```",
+			limit:    30,
+			expected: []string{"```go\nline one\nline two\n```", "```go\nline three\n```"},
+		},
+		{
+			name:     "Splits before code fence",
+			content:  "Some introduction text\n```\ncode\n```",
+			limit:    30,
+			expected: []string{"Some introduction text", "```\ncode\n```"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := splitMessage(tt.content, tt.limit)
+			if strings.Join(chunks, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("expected %q, got %q", tt.expected, chunks)
+			}
+			for _, chunk := range chunks {
+				if len(chunk) > tt.limit {
+					t.Errorf("chunk %q exceeds limit %d", chunk, tt.limit)
+				}
+				if open, _ := openCodeBlock(chunk); open {
+					t.Errorf("chunk %q leaves a code block open", chunk)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitMessage_Unicode(t *testing.T) {
+	content := strings.Repeat("🐻", 10)
+	chunks := splitMessage(content, 9)
+	for _, chunk := range chunks {
+		if !utf8.ValidString(chunk) {
+			t.Errorf("chunk %q splits a character", chunk)
+		}
+	}
+	if strings.Join(chunks, "") != content {
+		t.Errorf("expected chunks to rebuild the content, got %q", chunks)
+	}
+}
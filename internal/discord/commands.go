@@ -0,0 +1,214 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"rsandz/bearlawyergo/internal/config"
+	"rsandz/bearlawyergo/internal/message"
+	"rsandz/bearlawyergo/internal/orchestrator"
+
+	discordgo "github.com/bwmarrin/discordgo"
+)
+
+// command is a slash command the bot registers at startup.
+type command struct {
+	definition *discordgo.ApplicationCommand
+	// deferred commands acknowledge the interaction straight away and reply by editing the response later,
+	// so that slow handlers do not hit Discord's three second interaction timeout.
+	deferred bool
+	handle   func(ctx context.Context, i *discordgo.InteractionCreate)
+}
+
+// commandList declares the slash commands of the bot.
+func (b *Bot) commandList() []command {
+	return []command{
+		{
+			definition: &discordgo.ApplicationCommand{
+				Name:        "ask",
+				Description: "Ask Bear Lawyer a question",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "question",
+						Description: "What you would like to ask",
+						Required:    true,
+					},
+				},
+			},
+			deferred: true,
+			handle:   b.handleAsk,
+		},
+		{
+			definition: &discordgo.ApplicationCommand{
+				Name:        "reset",
+				Description: "Forget the conversation in this channel",
+			},
+			handle: b.handleReset,
+		},
+		{
+			definition: &discordgo.ApplicationCommand{
+				Name:        "persona",
+				Description: "Show or change the persona Bear Lawyer uses in this channel",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "name",
+						Description: "The persona to switch to",
+					},
+				},
+			},
+			handle: b.handlePersona,
+		},
+		{
+			definition: &discordgo.ApplicationCommand{
+				Name:        "help",
+				Description: "List Bear Lawyer's commands",
+			},
+			handle: b.handleHelp,
+		},
+	}
+}
+
+// registerCommands overwrites the bot's global slash commands with the declared commands.
+func (b *Bot) registerCommands() error {
+	definitions := make([]*discordgo.ApplicationCommand, len(b.commands))
+	for i, cmd := range b.commands {
+		definitions[i] = cmd.definition
+	}
+	if _, err := b.discord.ApplicationCommandBulkOverwrite(b.discord.State.User.ID, "", definitions); err != nil {
+		return fmt.Errorf("failed to register slash commands: %w", err)
+	}
+	return nil
+}
+
+func (b *Bot) handleInteraction(session *discordgo.Session, i *discordgo.InteractionCreate) {
+	ctx := orchestrator.WithTraceID(context.Background(), b.logger)
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		b.handleCommand(ctx, session, i)
+	case discordgo.InteractionMessageComponent:
+		b.handleComponent(ctx, i)
+	case discordgo.InteractionModalSubmit:
+		b.handleModalSubmit(ctx, i)
+	}
+}
+
+func (b *Bot) handleCommand(ctx context.Context, session *discordgo.Session, i *discordgo.InteractionCreate) {
+	name := i.ApplicationCommandData().Name
+	var cmd *command
+	for idx := range b.commands {
+		if b.commands[idx].definition.Name == name {
+			cmd = &b.commands[idx]
+			break
+		}
+	}
+	if cmd == nil {
+		b.logger.WarnContext(ctx, "Received unknown slash command", "command", name)
+		return
+	}
+
+	b.logger.InfoContext(ctx, "Handling slash command", "command", name, "user", interactionUser(i).ID, "channel_id", i.ChannelID)
+	if cmd.deferred {
+		err := session.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		})
+		if err != nil {
+			b.logger.ErrorContext(ctx, "Failed to defer slash command", "command", name, "error", err)
+			return
+		}
+	}
+	cmd.handle(ctx, i)
+}
+
+func (b *Bot) handleAsk(ctx context.Context, i *discordgo.InteractionCreate) {
+	question := optionString(i, "question")
+	user := interactionUser(i)
+
+	msg := message.NewMessage(user.Username, b.normalizer(i.GuildID, nil).normalize(question), message.UserRole)
+	msg.Timestamp = time.Now()
+	if i.Member != nil {
+		msg.Roles = i.Member.Roles
+	}
+
+	req := message.NewRequest(*msg, nil, i.ChannelID)
+	req.RawContent = question
+	b.locate(ctx, req, i.ChannelID)
+	b.respond(ctx, req, HistoryRequest{RequesterID: user.ID}, &interactionTarget{session: b.discord, interaction: i.Interaction})
+}
+
+func (b *Bot) handleReset(ctx context.Context, i *discordgo.InteractionCreate) {
+	if err := b.dispatcher.Forget(ctx, i.ChannelID); err != nil {
+		b.logger.ErrorContext(ctx, "Failed to clear conversation", "error", err, "channel_id", i.ChannelID)
+		b.replyEphemeral(ctx, i, "Sorry! I could not forget this conversation. Please try again later.")
+		return
+	}
+	// History is read back from Discord, so messages before the reset must be left out of it too.
+	b.setReset(i.ChannelID, i.ID)
+	b.replyEphemeral(ctx, i, "Order is restored. I have forgotten our conversation in this channel.")
+}
+
+func (b *Bot) handlePersona(ctx context.Context, i *discordgo.InteractionCreate) {
+	available := strings.Join(b.prompts.PersonaNames(), ", ")
+	name := optionString(i, "name")
+	if name == "" {
+		req := &message.Request{}
+		b.locate(ctx, req, i.ChannelID)
+		current := b.persona(req.ConversationID(), req.ParentID)
+		if current == "" {
+			current = config.DefaultPersona
+		}
+		b.replyEphemeral(ctx, i, fmt.Sprintf("Current persona: %s. Available personas: %s.", current, available))
+		return
+	}
+
+	if _, ok := b.prompts.PersonaPrompt(name); !ok {
+		b.replyEphemeral(ctx, i, fmt.Sprintf("Unknown persona %q. Available personas: %s.", name, available))
+		return
+	}
+	b.setPersona(i.ChannelID, name)
+	b.replyEphemeral(ctx, i, fmt.Sprintf("Persona changed to %s.", name))
+}
+
+func (b *Bot) handleHelp(ctx context.Context, i *discordgo.InteractionCreate) {
+	var help strings.Builder
+	help.WriteString("Mention me with a question, or use one of these commands:\n")
+	for _, cmd := range b.commands {
+		fmt.Fprintf(&help, "`/%s` - %s\n", cmd.definition.Name, cmd.definition.Description)
+	}
+	b.replyEphemeral(ctx, i, help.String())
+}
+
+// replyEphemeral responds to the interaction with a message only the invoking user can see.
+func (b *Bot) replyEphemeral(ctx context.Context, i *discordgo.InteractionCreate, content string) {
+	err := b.discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		b.logger.ErrorContext(ctx, "Failed to respond to interaction", "error", err, "channel_id", i.ChannelID)
+	}
+}
+
+// interactionUser returns the user who triggered the interaction, in a guild or a DM.
+func interactionUser(i *discordgo.InteractionCreate) *discordgo.User {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User
+	}
+	return i.User
+}
+
+// optionString returns the value of the named string option, or an empty string if it was not given.
+func optionString(i *discordgo.InteractionCreate, name string) string {
+	for _, option := range i.ApplicationCommandData().Options {
+		if option.Name == name {
+			return option.StringValue()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,278 @@
+package discord
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"rsandz/bearlawyergo/internal/message"
+	"strings"
+	"sync"
+	"time"
+
+	discordgo "github.com/bwmarrin/discordgo"
+)
+
+const componentExpiredReply = "This matter has been closed. The buttons on that message have expired."
+
+const (
+	// DefaultComponentTTL is how long buttons and select menus keep working after they are sent.
+	DefaultComponentTTL = time.Hour
+	// componentIDPrefix marks custom IDs issued by the component registry.
+	componentIDPrefix = "cmp:"
+	// maxButtonsPerRow and maxComponentRows are Discord's limits on components per message.
+	maxButtonsPerRow = 5
+	maxComponentRows = 5
+	// maxSelectOptions is Discord's limit on the options of a select menu.
+	maxSelectOptions = 25
+)
+
+// componentRegistry maps the custom IDs sent to Discord to the components they stand for.
+// Discord limits custom IDs to 100 characters, so components and their state are kept here instead.
+// Entries expire after the registry's TTL.
+type componentRegistry struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]componentEntry
+	now     func() time.Time
+}
+
+type componentEntry struct {
+	component message.Component
+	expires   time.Time
+}
+
+func newComponentRegistry(ttl time.Duration) *componentRegistry {
+	return &componentRegistry{
+		ttl:     ttl,
+		entries: make(map[string]componentEntry),
+		now:     time.Now,
+	}
+}
+
+// register stores the component and returns its custom ID.
+func (r *componentRegistry) register(component message.Component) (string, error) {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to generate component ID: %w", err)
+	}
+	id := componentIDPrefix + hex.EncodeToString(bytes)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	for key, entry := range r.entries {
+		if !now.Before(entry.expires) {
+			delete(r.entries, key)
+		}
+	}
+	r.entries[id] = componentEntry{component: component, expires: now.Add(r.ttl)}
+	return id, nil
+}
+
+// lookup returns the component with the custom ID, or false if it is unknown or has expired.
+func (r *componentRegistry) lookup(id string) (message.Component, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.entries[id]
+	if !ok {
+		return message.Component{}, false
+	}
+	if !r.now().Before(entry.expires) {
+		delete(r.entries, id)
+		return message.Component{}, false
+	}
+	return entry.component, true
+}
+
+// isComponentID reports whether the custom ID was issued by the component registry.
+func isComponentID(id string) bool {
+	return strings.HasPrefix(id, componentIDPrefix)
+}
+
+// toComponents lays the components out in Discord action rows and registers the ones that fit.
+// Adjacent buttons share rows and each select menu takes a row of its own. Components past Discord's
+// limit of rows, and select menu options past Discord's limit of options, are dropped.
+func (r *componentRegistry) toComponents(components []message.Component) ([]discordgo.MessageComponent, error) {
+	layout, err := layoutComponents(components)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]discordgo.MessageComponent, 0, len(layout))
+	for _, row := range layout {
+		var actions discordgo.ActionsRow
+		for _, component := range row {
+			id, err := r.register(component)
+			if err != nil {
+				return nil, err
+			}
+			switch component.Kind {
+			case message.ButtonComponent:
+				actions.Components = append(actions.Components, discordgo.Button{
+					Label:    component.Label,
+					Style:    discordgo.SecondaryButton,
+					CustomID: id,
+				})
+			case message.SelectComponent:
+				menu := discordgo.SelectMenu{CustomID: id, Placeholder: component.Label}
+				for _, option := range component.Options[:min(len(component.Options), maxSelectOptions)] {
+					menu.Options = append(menu.Options, discordgo.SelectMenuOption{Label: option.Label, Value: option.Value})
+				}
+				actions.Components = append(actions.Components, menu)
+			}
+		}
+		rows = append(rows, actions)
+	}
+	return rows, nil
+}
+
+// layoutComponents groups the components into the rows they are shown in, up to Discord's limit of rows.
+func layoutComponents(components []message.Component) ([][]message.Component, error) {
+	var rows [][]message.Component
+	var buttons []message.Component
+	flush := func() {
+		if len(buttons) > 0 {
+			rows = append(rows, buttons)
+			buttons = nil
+		}
+	}
+
+	for _, component := range components {
+		switch component.Kind {
+		case message.ButtonComponent:
+			if len(buttons) == maxButtonsPerRow {
+				flush()
+			}
+			buttons = append(buttons, component)
+		case message.SelectComponent:
+			flush()
+			rows = append(rows, []message.Component{component})
+		default:
+			return nil, fmt.Errorf("unknown component kind %q", component.Kind)
+		}
+	}
+	flush()
+
+	if len(rows) > maxComponentRows {
+		rows = rows[:maxComponentRows]
+	}
+	return rows, nil
+}
+
+// toModal builds the Discord modal shown for a button, submitted under the custom ID.
+func toModal(id string, modal *message.Modal) *discordgo.InteractionResponseData {
+	var rows []discordgo.MessageComponent
+	for _, field := range modal.Fields {
+		style := discordgo.TextInputShort
+		if field.Long {
+			style = discordgo.TextInputParagraph
+		}
+		rows = append(rows, discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.TextInput{
+				CustomID:    field.Name,
+				Label:       field.Label,
+				Placeholder: field.Placeholder,
+				Style:       style,
+				Required:    field.Required,
+			},
+		}})
+	}
+	return &discordgo.InteractionResponseData{CustomID: id, Title: modal.Title, Components: rows}
+}
+
+// modalFields returns the values entered in a submitted modal, keyed by field name.
+func modalFields(data discordgo.ModalSubmitInteractionData) map[string]string {
+	fields := make(map[string]string)
+	for _, row := range data.Components {
+		actions, ok := row.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, component := range actions.Components {
+			if input, ok := component.(*discordgo.TextInput); ok {
+				fields[input.CustomID] = input.Value
+			}
+		}
+	}
+	return fields
+}
+
+// handleComponent handles a press of a button or a choice in a select menu sent with a reply.
+// Buttons with a modal show the modal, and the interaction is handled once it is submitted.
+func (b *Bot) handleComponent(ctx context.Context, i *discordgo.InteractionCreate) {
+	data := i.MessageComponentData()
+	if !isComponentID(data.CustomID) {
+		return
+	}
+	component, ok := b.components.lookup(data.CustomID)
+	if !ok {
+		b.replyEphemeral(ctx, i, componentExpiredReply)
+		return
+	}
+
+	if component.Modal != nil {
+		// The modal is submitted under its own ID so that the button keeps working if the modal is dismissed.
+		id, err := b.components.register(component)
+		if err != nil {
+			b.logger.ErrorContext(ctx, "Failed to register modal", "error", err)
+			return
+		}
+		err = b.discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseModal,
+			Data: toModal(id, component.Modal),
+		})
+		if err != nil {
+			b.logger.ErrorContext(ctx, "Failed to show modal", "error", err, "action", component.Action)
+		}
+		return
+	}
+
+	b.respondInteraction(ctx, i, &message.Interaction{Component: component, Values: data.Values})
+}
+
+// handleModalSubmit handles a modal shown for a button.
+func (b *Bot) handleModalSubmit(ctx context.Context, i *discordgo.InteractionCreate) {
+	data := i.ModalSubmitData()
+	if !isComponentID(data.CustomID) {
+		return
+	}
+	component, ok := b.components.lookup(data.CustomID)
+	if !ok {
+		b.replyEphemeral(ctx, i, componentExpiredReply)
+		return
+	}
+	b.respondInteraction(ctx, i, &message.Interaction{Component: component, Fields: modalFields(data)})
+}
+
+// respondInteraction routes the interaction back to the handler that added the component and
+// sends its response as a new reply.
+func (b *Bot) respondInteraction(ctx context.Context, i *discordgo.InteractionCreate, interaction *message.Interaction) {
+	err := b.discord.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		b.logger.ErrorContext(ctx, "Failed to defer component interaction", "error", err, "action", interaction.Component.Action)
+		return
+	}
+
+	user := interactionUser(i)
+	b.logger.InfoContext(ctx, "Handling component interaction", "action", interaction.Component.Action, "user", user.ID, "channel_id", i.ChannelID)
+	msg := message.NewMessage(user.Username, interaction.Component.Label, message.UserRole)
+	msg.Timestamp = time.Now()
+	if i.Member != nil {
+		msg.Roles = i.Member.Roles
+	}
+	req := message.NewRequest(*msg, nil, i.ChannelID)
+	b.locate(ctx, req, i.ChannelID)
+	b.prepare(ctx, req, HistoryRequest{RequesterID: user.ID})
+	interaction.Request = req
+
+	target := &interactionTarget{session: b.discord, interaction: i.Interaction}
+	resp, err := b.dispatcher.DispatchInteraction(ctx, interaction)
+	if err != nil {
+		b.handleError(ctx, req.ConversationID(), target, nil, err)
+		return
+	}
+	b.reply(ctx, target, nil, resp)
+}
@@ -0,0 +1,104 @@
+package discord
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"rsandz/bearlawyergo/internal/message"
+
+	discordgo "github.com/bwmarrin/discordgo"
+)
+
+func TestComponentRegistry(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	registry := newComponentRegistry(time.Minute)
+	registry.now = func() time.Time { return now }
+
+	button := message.NewButton("Regenerate", "regenerate", map[string]string{"question": "What is a tort?"})
+	button.Route = "llm"
+	id, err := registry.register(button)
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	if !isComponentID(id) || len(id) > 100 {
+		t.Errorf("unexpected custom ID %q", id)
+	}
+
+	component, ok := registry.lookup(id)
+	if !ok || component.Action != "regenerate" || component.Route != "llm" || component.State["question"] != "What is a tort?" {
+		t.Errorf("expected registered component, got %+v", component)
+	}
+	if _, ok := registry.lookup(componentIDPrefix + "unknown"); ok {
+		t.Error("expected unknown ID not to be found")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := registry.lookup(id); ok {
+		t.Error("expected component to expire")
+	}
+}
+
+func TestComponentRegistry_Layout(t *testing.T) {
+	registry := newComponentRegistry(time.Minute)
+	var components []message.Component
+	for range 6 {
+		components = append(components, message.NewButton("Option", "vote", nil))
+	}
+	components = append(components,
+		message.NewSelect("Verdict", "verdict", nil, message.SelectOption{Label: "Guilty", Value: "guilty"}),
+		message.NewButton("Appeal", "appeal", nil),
+	)
+
+	rows, err := registry.toComponents(components)
+	if err != nil {
+		t.Fatalf("toComponents failed: %v", err)
+	}
+	var sizes []int
+	for _, row := range rows {
+		sizes = append(sizes, len(row.(discordgo.ActionsRow).Components))
+	}
+	expected := []int{5, 1, 1, 1}
+	if len(sizes) != len(expected) {
+		t.Fatalf("expected rows of %v, got %v", expected, sizes)
+	}
+	for i := range expected {
+		if sizes[i] != expected[i] {
+			t.Fatalf("expected rows of %v, got %v", expected, sizes)
+		}
+	}
+	if _, ok := rows[2].(discordgo.ActionsRow).Components[0].(discordgo.SelectMenu); !ok {
+		t.Error("expected the select menu to have its own row")
+	}
+}
+
+func TestComponentRegistry_Limits(t *testing.T) {
+	registry := newComponentRegistry(time.Minute)
+	var components []message.Component
+	for range 30 {
+		components = append(components, message.NewButton("Option", "vote", nil))
+	}
+
+	rows, err := registry.toComponents(components)
+	if err != nil {
+		t.Fatalf("toComponents failed: %v", err)
+	}
+	if len(rows) != maxComponentRows {
+		t.Fatalf("expected %d rows, got %d", maxComponentRows, len(rows))
+	}
+	if len(registry.entries) != maxComponentRows*maxButtonsPerRow {
+		t.Errorf("expected only the %d buttons sent to be registered, got %d", maxComponentRows*maxButtonsPerRow, len(registry.entries))
+	}
+
+	var options []message.SelectOption
+	for i := range 30 {
+		options = append(options, message.SelectOption{Label: fmt.Sprint(i), Value: fmt.Sprint(i)})
+	}
+	rows, err = registry.toComponents([]message.Component{message.NewSelect("Verdict", "verdict", nil, options...)})
+	if err != nil {
+		t.Fatalf("toComponents failed: %v", err)
+	}
+	if menu := rows[0].(discordgo.ActionsRow).Components[0].(discordgo.SelectMenu); len(menu.Options) != maxSelectOptions {
+		t.Errorf("expected %d select options, got %d", maxSelectOptions, len(menu.Options))
+	}
+}
@@ -0,0 +1,43 @@
+package discord
+
+import (
+	"context"
+	"time"
+)
+
+// DMPolicy controls how the bot answers direct messages.
+type DMPolicy struct {
+	// Enabled answers every direct message. When disabled, direct messages are only answered if they mention the bot.
+	Enabled bool
+	// RateLimit is the number of direct messages each user may send per RateWindow. Zero or less is unlimited.
+	RateLimit  int
+	RateWindow time.Duration
+}
+
+// DefaultDMPolicy leaves direct message mode off, with a limit that keeps a single user from monopolising the model.
+var DefaultDMPolicy = DMPolicy{
+	Enabled:    false,
+	RateLimit:  5,
+	RateWindow: time.Minute,
+}
+
+const dmRateLimitedReply = "A procedural irregularity has occurred. You are filing motions faster than this court can hear them. Please wait a moment."
+
+// SetDMPolicy sets how direct messages are answered.
+func (b *Bot) SetDMPolicy(policy DMPolicy) {
+	b.dmPolicy = policy
+	b.dmLimiter = newRateLimiter(policy.RateLimit, policy.RateWindow)
+}
+
+// allowDM reports whether the direct message conversation may be answered now, telling the user if it may not.
+// Discord gives each user a single direct message channel with the bot, so the channel identifies the user.
+func (b *Bot) allowDM(ctx context.Context, channelID string, target replyTarget) bool {
+	if b.dmLimiter.allow(channelID) {
+		return true
+	}
+	b.logger.InfoContext(ctx, "Direct message rate limited", "channel_id", channelID)
+	if _, err := target.send(dmRateLimitedReply); err != nil {
+		b.logger.WarnContext(ctx, "Failed to send rate limit reply", "error", err, "channel_id", channelID)
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"rsandz/bearlawyergo/internal/message"
+	"slices"
+	"strconv"
+
+	discordgo "github.com/bwmarrin/discordgo"
+)
+
+const (
+	// DefaultHistoryLimit is the number of messages resolvers include in the history by default.
+	DefaultHistoryLimit = 10
+	// DefaultReplyChainDepth is how many replies ReplyChainResolver follows back by default.
+	DefaultReplyChainDepth = 10
+	// maxFetchLimit is the most messages Discord returns for a single channel request.
+	maxFetchLimit = 100
+)
+
+// Names of the history strategies accepted by NewHistoryResolver.
+const (
+	LastNStrategy           = "last_n"
+	ReplyChainStrategy      = "reply_chain"
+	BotConversationStrategy = "bot_conversation"
+)
+
+// MessageFetcher reads messages from Discord. It is satisfied by *discordgo.Session.
+type MessageFetcher interface {
+	ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error)
+	ChannelMessage(channelID, messageID string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+}
+
+// HistoryRequest describes the conversation whose history is being resolved.
+type HistoryRequest struct {
+	// ChannelID is the channel or thread the request was sent in.
+	ChannelID string
+	// Message is the message being answered. Nil for slash commands, which have no message.
+	Message *discordgo.Message
+	// RequesterID is the Discord user ID of the user being answered.
+	RequesterID string
+	// BotID is the Discord user ID of the bot.
+	BotID string
+	// Normalize converts a message's content to plain text for the history. Raw content is used if nil.
+	Normalize func(m *discordgo.Message) string
+	// After is the ID of the message or interaction the history starts after, such as the /reset that cleared
+	// the conversation. Empty includes every message.
+	After string
+}
+
+// HistoryResolver chooses which earlier Discord messages make up the history of a request.
+// History is returned oldest first and never includes the message being answered.
+type HistoryResolver interface {
+	Resolve(ctx context.Context, fetcher MessageFetcher, req HistoryRequest) ([]message.Message, error)
+}
+
+// NewHistoryResolver returns the resolver for the named strategy with its default settings.
+func NewHistoryResolver(strategy string) (HistoryResolver, error) {
+	switch strategy {
+	case LastNStrategy:
+		return &LastNResolver{Limit: DefaultHistoryLimit}, nil
+	case ReplyChainStrategy:
+		return &ReplyChainResolver{Depth: DefaultReplyChainDepth}, nil
+	case BotConversationStrategy:
+		return &BotConversationResolver{Limit: DefaultHistoryLimit, Scan: maxFetchLimit}, nil
+	default:
+		return nil, fmt.Errorf("unknown history strategy %q", strategy)
+	}
+}
+
+// LastNResolver uses the most recent messages in the channel.
+type LastNResolver struct {
+	Limit int
+}
+
+func (r *LastNResolver) Resolve(ctx context.Context, fetcher MessageFetcher, req HistoryRequest) ([]message.Message, error) {
+	messages, err := fetchRecent(fetcher, req, r.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return toHistory(messages, req), nil
+}
+
+// ReplyChainResolver follows the replies that led to the message back up to Depth messages.
+// Requests that are not replies have no history.
+type ReplyChainResolver struct {
+	Depth int
+}
+
+func (r *ReplyChainResolver) Resolve(ctx context.Context, fetcher MessageFetcher, req HistoryRequest) ([]message.Message, error) {
+	if req.Message == nil {
+		return nil, nil
+	}
+
+	var chain []*discordgo.Message
+	current := req.Message
+	for len(chain) < r.Depth && current.MessageReference != nil {
+		ref := current.MessageReference
+		// Discord includes the replied-to message on the reply, but not on messages fetched further up the chain.
+		parent := current.ReferencedMessage
+		if parent == nil {
+			channelID := ref.ChannelID
+			if channelID == "" {
+				channelID = req.ChannelID
+			}
+			var err error
+			parent, err = fetcher.ChannelMessage(channelID, ref.MessageID)
+			if err != nil {
+				// Keep the part of the chain that was resolved; the rest may have been deleted.
+				if len(chain) > 0 {
+					break
+				}
+				return nil, fmt.Errorf("failed to fetch replied-to message %s: %w", ref.MessageID, err)
+			}
+		}
+		chain = append(chain, parent)
+		current = parent
+	}
+
+	slices.Reverse(chain)
+	return toHistory(chain, req), nil
+}
+
+// BotConversationResolver uses the most recent messages between the requester and the bot, skipping other chatter.
+// Up to Scan recent channel messages are searched for Limit messages.
+type BotConversationResolver struct {
+	Limit int
+	Scan  int
+}
+
+func (r *BotConversationResolver) Resolve(ctx context.Context, fetcher MessageFetcher, req HistoryRequest) ([]message.Message, error) {
+	messages, err := fetchRecent(fetcher, req, r.Scan)
+	if err != nil {
+		return nil, err
+	}
+
+	var conversation []*discordgo.Message
+	for _, m := range messages {
+		if m.Author == nil || (m.Author.ID != req.RequesterID && m.Author.ID != req.BotID) {
+			continue
+		}
+		conversation = append(conversation, m)
+	}
+	if len(conversation) > r.Limit {
+		conversation = conversation[len(conversation)-r.Limit:]
+	}
+	return toHistory(conversation, req), nil
+}
+
+// fetchRecent returns up to limit messages sent before the request, oldest first.
+func fetchRecent(fetcher MessageFetcher, req HistoryRequest, limit int) ([]*discordgo.Message, error) {
+	limit = min(limit, maxFetchLimit)
+	if limit <= 0 {
+		return nil, nil
+	}
+	var beforeID string
+	if req.Message != nil {
+		beforeID = req.Message.ID
+	}
+	messages, err := fetcher.ChannelMessages(req.ChannelID, limit, beforeID, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch channel messages: %w", err)
+	}
+	// Discord returns messages from newest to oldest.
+	slices.Reverse(messages)
+	return messages, nil
+}
+
+// toHistory converts Discord messages to history, marking the bot's own messages with the bot role.
+// Messages sent before the request's After are left out.
+func toHistory(messages []*discordgo.Message, req HistoryRequest) []message.Message {
+	history := make([]message.Message, 0, len(messages))
+	for _, dm := range messages {
+		if dm.Author == nil || !sentAfter(dm.ID, req.After) {
+			continue
+		}
+		role := message.UserRole
+		if dm.Author.ID == req.BotID {
+			role = message.BotRole
+		}
+		content := dm.Content
+		if req.Normalize != nil {
+			content = req.Normalize(dm)
+		}
+		msg := message.NewMessage(dm.Author.Username, content, role)
+		msg.Timestamp = dm.Timestamp
+		history = append(history, *msg)
+	}
+	return history
+}
+
+// sentAfter reports whether the message with the ID was sent after the message or interaction with the ID after.
+// Discord IDs are snowflakes, which increase over time.
+func sentAfter(id, after string) bool {
+	if after == "" {
+		return true
+	}
+	idValue, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return true
+	}
+	afterValue, err := strconv.ParseUint(after, 10, 64)
+	if err != nil {
+		return true
+	}
+	return idValue > afterValue
+}
@@ -0,0 +1,179 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"rsandz/bearlawyergo/internal/message"
+
+	discordgo "github.com/bwmarrin/discordgo"
+)
+
+// fakeFetcher serves messages from memory. channel holds the channel's messages oldest first.
+type fakeFetcher struct {
+	channel []*discordgo.Message
+	err     error
+}
+
+func (f *fakeFetcher) ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	end := len(f.channel)
+	if beforeID != "" {
+		end = slices.IndexFunc(f.channel, func(m *discordgo.Message) bool { return m.ID == beforeID })
+	}
+	start := max(0, end-limit)
+	messages := slices.Clone(f.channel[start:end])
+	slices.Reverse(messages)
+	return messages, nil
+}
+
+func (f *fakeFetcher) ChannelMessage(channelID, messageID string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	for _, m := range f.channel {
+		if m.ID == messageID {
+			return m, nil
+		}
+	}
+	return nil, errors.New("unknown message")
+}
+
+func discordMessage(id, authorID, content, replyTo string) *discordgo.Message {
+	m := &discordgo.Message{
+		ID:        id,
+		ChannelID: "channel",
+		Content:   content,
+		Author:    &discordgo.User{ID: authorID, Username: authorID},
+	}
+	if replyTo != "" {
+		m.MessageReference = &discordgo.MessageReference{MessageID: replyTo, ChannelID: "channel"}
+	}
+	return m
+}
+
+func contents(history []message.Message) []string {
+	var result []string
+	for _, msg := range history {
+		result = append(result, msg.Content)
+	}
+	return result
+}
+
+func TestHistoryResolvers(t *testing.T) {
+	channel := []*discordgo.Message{
+		discordMessage("1", "alice", "What is a tort?", ""),
+		discordMessage("2", "bot", "A civil wrong.", "1"),
+		discordMessage("3", "carol", "Anyone up for games?", ""),
+		discordMessage("4", "alice", "Give an example.", "2"),
+		discordMessage("5", "bot", "Trespass upon a den.", "4"),
+		discordMessage("6", "carol", "Hello?", ""),
+		discordMessage("7", "alice", "And the remedy?", "5"),
+	}
+	latest := channel[len(channel)-1]
+
+	tests := []struct {
+		name     string
+		resolver HistoryResolver
+		message  *discordgo.Message
+		after    string
+		expected []string
+	}{
+		{
+			name:     "Last N",
+			resolver: &LastNResolver{Limit: 3},
+			message:  latest,
+			expected: []string{"Give an example.", "Trespass upon a den.", "Hello?"},
+		},
+		{
+			name:     "Last N without message",
+			resolver: &LastNResolver{Limit: 2},
+			expected: []string{"Hello?", "And the remedy?"},
+		},
+		{
+			name:     "Reply chain",
+			resolver: &ReplyChainResolver{Depth: 10},
+			message:  latest,
+			expected: []string{"What is a tort?", "A civil wrong.", "Give an example.", "Trespass upon a den."},
+		},
+		{
+			name:     "Reply chain depth",
+			resolver: &ReplyChainResolver{Depth: 2},
+			message:  latest,
+			expected: []string{"Give an example.", "Trespass upon a den."},
+		},
+		{
+			name:     "Reply chain not a reply",
+			resolver: &ReplyChainResolver{Depth: 10},
+			message:  channel[2],
+			expected: nil,
+		},
+		{
+			name:     "Bot conversation",
+			resolver: &BotConversationResolver{Limit: 3, Scan: 100},
+			message:  latest,
+			expected: []string{"A civil wrong.", "Give an example.", "Trespass upon a den."},
+		},
+		{
+			name:     "Last N after reset",
+			resolver: &LastNResolver{Limit: 10},
+			message:  latest,
+			after:    "4",
+			expected: []string{"Trespass upon a den.", "Hello?"},
+		},
+		{
+			name:     "Reply chain after reset",
+			resolver: &ReplyChainResolver{Depth: 10},
+			message:  latest,
+			after:    "2",
+			expected: []string{"Give an example.", "Trespass upon a den."},
+		},
+		{
+			name:     "Bot conversation after reset",
+			resolver: &BotConversationResolver{Limit: 10, Scan: 100},
+			message:  latest,
+			after:    "5",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := HistoryRequest{ChannelID: "channel", Message: tt.message, RequesterID: "alice", BotID: "bot", After: tt.after}
+			history, err := tt.resolver.Resolve(context.Background(), &fakeFetcher{channel: channel}, req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := contents(history); !slices.Equal(got, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+			for _, msg := range history {
+				if (msg.User == "bot") != (msg.Role == message.BotRole) {
+					t.Errorf("message from %s has role %s", msg.User, msg.Role)
+				}
+			}
+		})
+	}
+}
+
+func TestHistoryResolvers_FetchError(t *testing.T) {
+	fetchErr := errors.New("discord unavailable")
+	req := HistoryRequest{ChannelID: "channel", RequesterID: "alice", BotID: "bot"}
+	for _, resolver := range []HistoryResolver{&LastNResolver{Limit: 10}, &BotConversationResolver{Limit: 10, Scan: 100}} {
+		if _, err := resolver.Resolve(context.Background(), &fakeFetcher{err: fetchErr}, req); !errors.Is(err, fetchErr) {
+			t.Errorf("%T: expected fetch error, got %v", resolver, err)
+		}
+	}
+}
+
+func TestNewHistoryResolver(t *testing.T) {
+	for _, strategy := range []string{LastNStrategy, ReplyChainStrategy, BotConversationStrategy} {
+		if _, err := NewHistoryResolver(strategy); err != nil {
+			t.Errorf("strategy %q: unexpected error: %v", strategy, err)
+		}
+	}
+	if _, err := NewHistoryResolver("everything"); err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+}
@@ -0,0 +1,105 @@
+package discord
+
+import (
+	"regexp"
+	"strings"
+
+	discordgo "github.com/bwmarrin/discordgo"
+)
+
+var (
+	userMentionPattern    = regexp.MustCompile(`<@!?(\d+)>`)
+	roleMentionPattern    = regexp.MustCompile(`<@&(\d+)>`)
+	channelMentionPattern = regexp.MustCompile(`<#(\d+)>`)
+	customEmojiPattern    = regexp.MustCompile(`<a?:(\w+):\d+>`)
+)
+
+// normalizer rewrites Discord markup into the plain text a user sees.
+// Each lookup returns the display name for an ID, or false if the ID is unknown.
+// Mentions that cannot be resolved are left as they are.
+type normalizer struct {
+	botID   string
+	user    func(id string) (string, bool)
+	role    func(id string) (string, bool)
+	channel func(id string) (string, bool)
+}
+
+// normalize resolves user, role and channel mentions to display names, removes mentions of the bot
+// and converts custom emoji to :name:.
+// Only the whitespace around the bot's mentions is collapsed, so indentation such as in code blocks is kept.
+func (n *normalizer) normalize(content string) string {
+	if n.botID != "" {
+		botMentionPattern := regexp.MustCompile(`\s*<@!?` + regexp.QuoteMeta(n.botID) + `>\s*`)
+		content = botMentionPattern.ReplaceAllString(content, " ")
+	}
+	content = userMentionPattern.ReplaceAllStringFunc(content, func(token string) string {
+		if name, ok := n.user(userMentionPattern.FindStringSubmatch(token)[1]); ok {
+			return "@" + name
+		}
+		return token
+	})
+	content = roleMentionPattern.ReplaceAllStringFunc(content, func(token string) string {
+		if name, ok := n.role(roleMentionPattern.FindStringSubmatch(token)[1]); ok {
+			return "@" + name
+		}
+		return token
+	})
+	content = channelMentionPattern.ReplaceAllStringFunc(content, func(token string) string {
+		if name, ok := n.channel(channelMentionPattern.FindStringSubmatch(token)[1]); ok {
+			return "#" + name
+		}
+		return token
+	})
+	content = customEmojiPattern.ReplaceAllString(content, ":$1:")
+	return strings.TrimSpace(content)
+}
+
+// normalizer returns a normalizer that resolves names in the guild from the session state.
+// mentions are the users mentioned by the message, which Discord sends with it.
+func (b *Bot) normalizer(guildID string, mentions []*discordgo.User) *normalizer {
+	state := b.discord.State
+	return &normalizer{
+		botID: state.User.ID,
+		user: func(id string) (string, bool) {
+			if guildID != "" {
+				if member, err := state.Member(guildID, id); err == nil {
+					if member.Nick != "" {
+						return member.Nick, true
+					}
+					if member.User != nil {
+						return member.User.DisplayName(), true
+					}
+				}
+			}
+			for _, user := range mentions {
+				if user.ID == id {
+					return user.DisplayName(), true
+				}
+			}
+			return "", false
+		},
+		role: func(id string) (string, bool) {
+			if guildID == "" {
+				return "", false
+			}
+			role, err := state.Role(guildID, id)
+			if err != nil {
+				return "", false
+			}
+			return role.Name, true
+		},
+		channel: func(id string) (string, bool) {
+			ch, err := state.Channel(id)
+			if err != nil {
+				return "", false
+			}
+			return ch.Name, true
+		},
+	}
+}
+
+// normalizeMessage returns the content of a message in the guild as the user sees it.
+// The guild is passed separately because messages fetched from the REST API do not carry their guild ID.
+func (b *Bot) normalizeMessage(guildID string, m *discordgo.Message) string {
+	return b.normalizer(guildID, m.Mentions).normalize(m.Content)
+}
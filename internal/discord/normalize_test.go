@@ -0,0 +1,49 @@
+package discord
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	lookup := func(names map[string]string) func(id string) (string, bool) {
+		return func(id string) (string, bool) {
+			name, ok := names[id]
+			return name, ok
+		}
+	}
+	n := &normalizer{
+		botID:   "100",
+		user:    lookup(map[string]string{"200": "Alice"}),
+		role:    lookup(map[string]string{"300": "Jury"}),
+		channel: lookup(map[string]string{"400": "courtroom"}),
+	}
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{name: "Plain", content: "Order is restored.", expected: "Order is restored."},
+		{name: "Bot mention", content: "<@100> what is a tort?", expected: "what is a tort?"},
+		{name: "Bot nickname mention", content: "Hey <@!100> what is a tort?", expected: "Hey what is a tort?"},
+		{name: "User mention", content: "<@100> is <@200> guilty?", expected: "is @Alice guilty?"},
+		{name: "Role mention", content: "Ask the <@&300>", expected: "Ask the @Jury"},
+		{name: "Channel mention", content: "See <#400>", expected: "See #courtroom"},
+		{name: "Custom emoji", content: "Nice <:gavel:500> <a:dance:600>", expected: "Nice :gavel: :dance:"},
+		{name: "Unknown mentions kept", content: "<@201> in <#401>", expected: "<@201> in <#401>"},
+		{name: "Only bot mention", content: "<@100>", expected: ""},
+		{name: "Newlines kept", content: "<@100> first\nsecond", expected: "first\nsecond"},
+		{
+			name:     "Fenced code kept",
+			content:  "<@100> why?\n```go\nfunc main() {\n\tif  x {\n\t\tpanic(\"den\")\n\t}\n}\n```",
+			expected: "why?\n```go\nfunc main() {\n\tif  x {\n\t\tpanic(\"den\")\n\t}\n}\n```",
+		},
+		{name: "Indented code kept", content: "<@100> look:\n    x  =  1\n        y = 2", expected: "look:\n    x  =  1\n        y = 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := n.normalize(tt.content); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package discord
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter allows each key at most limit events within a sliding window.
+type rateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	events map[string][]time.Time
+	now    func() time.Time
+}
+
+// newRateLimiter creates a rate limiter. A limit of zero or less allows every event.
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:  limit,
+		window: window,
+		events: make(map[string][]time.Time),
+		now:    time.Now,
+	}
+}
+
+// allow records an event for the key and reports whether it is within the limit.
+// Rejected events are not recorded, so a key regains capacity as its earlier events leave the window.
+func (r *rateLimiter) allow(key string) bool {
+	if r.limit <= 0 {
+		return true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	cutoff := now.Add(-r.window)
+	events := r.events[key]
+	kept := events[:0]
+	for _, t := range events {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) >= r.limit {
+		r.events[key] = kept
+		return false
+	}
+	r.events[key] = append(kept, now)
+	r.prune(cutoff)
+	return true
+}
+
+// prune forgets keys with no events left in the window so idle users do not accumulate.
+func (r *rateLimiter) prune(cutoff time.Time) {
+	for key, events := range r.events {
+		if len(events) == 0 || !events[len(events)-1].After(cutoff) {
+			delete(r.events, key)
+		}
+	}
+}
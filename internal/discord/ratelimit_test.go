@@ -0,0 +1,36 @@
+package discord
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter(2, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	if !limiter.allow("alice") || !limiter.allow("alice") {
+		t.Fatal("expected the first two events to be allowed")
+	}
+	if limiter.allow("alice") {
+		t.Error("expected the third event within the window to be rejected")
+	}
+	if !limiter.allow("bob") {
+		t.Error("expected other keys to be limited separately")
+	}
+
+	now = now.Add(time.Minute + time.Second)
+	if !limiter.allow("alice") {
+		t.Error("expected events to be allowed once earlier events leave the window")
+	}
+}
+
+func TestRateLimiter_Unlimited(t *testing.T) {
+	limiter := newRateLimiter(0, time.Minute)
+	for range 100 {
+		if !limiter.allow("alice") {
+			t.Fatal("expected every event to be allowed without a limit")
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"rsandz/bearlawyergo/internal/message"
+
+	discordgo "github.com/bwmarrin/discordgo"
+)
+
+// render sends the response's outputs to the target in order.
+// Failures are logged and the remaining outputs are still sent.
+func (b *Bot) render(ctx context.Context, target replyTarget, outputs []message.Output) {
+	for _, output := range outputs {
+		var err error
+		switch output.Kind {
+		case message.TextOutput:
+			if !output.Reply && !output.Ephemeral {
+				b.deliver(ctx, target, nil, output.Text)
+				continue
+			}
+			for i, chunk := range splitMessage(output.Text, maxMessageLength) {
+				if _, err = target.sendOutput(&discordgo.MessageSend{Content: chunk}, output.Reply && i == 0, output.Ephemeral); err != nil {
+					break
+				}
+			}
+		case message.EmbedOutput:
+			_, err = target.sendOutput(&discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{toEmbed(output.Embed)}}, output.Reply, output.Ephemeral)
+		case message.FileOutput:
+			_, err = target.sendOutput(&discordgo.MessageSend{
+				Content: output.Text,
+				Files: []*discordgo.File{{
+					Name:        output.File.Name,
+					ContentType: output.File.ContentType,
+					Reader:      bytes.NewReader(output.File.Data),
+				}},
+			}, output.Reply, output.Ephemeral)
+		case message.ReactionOutput:
+			err = target.react(output.Text)
+		default:
+			b.logger.WarnContext(ctx, "Skipping unknown output", "kind", output.Kind)
+		}
+		if err != nil {
+			b.logger.WarnContext(ctx, "Failed to send output", "error", err, "kind", output.Kind)
+		}
+	}
+}
+
+// toEmbed converts an embed to Discord's representation.
+func toEmbed(embed *message.Embed) *discordgo.MessageEmbed {
+	result := &discordgo.MessageEmbed{
+		Title:       embed.Title,
+		Description: embed.Description,
+		URL:         embed.URL,
+		Color:       embed.Color,
+	}
+	for _, field := range embed.Fields {
+		result.Fields = append(result.Fields, &discordgo.MessageEmbedField{Name: field.Name, Value: field.Value, Inline: field.Inline})
+	}
+	if embed.Footer != "" {
+		result.Footer = &discordgo.MessageEmbedFooter{Text: embed.Footer}
+	}
+	if embed.ImageURL != "" {
+		result.Image = &discordgo.MessageEmbedImage{URL: embed.ImageURL}
+	}
+	return result
+}
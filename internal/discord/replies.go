@@ -0,0 +1,194 @@
+package discord
+
+import (
+	"container/list"
+	"context"
+	"rsandz/bearlawyergo/internal/orchestrator"
+	"sync"
+	"time"
+
+	discordgo "github.com/bwmarrin/discordgo"
+)
+
+const (
+	// DefaultReplyTrackingSize is how many requests' replies are remembered by default.
+	DefaultReplyTrackingSize = 1000
+	// DefaultReplyTrackingTTL is how long a request's replies are remembered by default.
+	DefaultReplyTrackingTTL = 24 * time.Hour
+)
+
+// replyTracker remembers which messages the bot sent in reply to each request message, so that the
+// replies can follow edits and deletions of the request. It holds at most size requests, forgetting the
+// oldest first, and forgets requests after the TTL.
+type replyTracker struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	entries map[string]*list.Element
+	now     func() time.Time
+}
+
+// trackedReply is the reply to one request message.
+type trackedReply struct {
+	requestID string
+	// channelID is where the reply was sent, which differs from the request's channel if a thread was started.
+	channelID  string
+	messageIDs []string
+	expires    time.Time
+}
+
+func newReplyTracker(size int, ttl time.Duration) *replyTracker {
+	return &replyTracker{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		now:     time.Now,
+	}
+}
+
+// put remembers the reply to the request message, replacing any earlier reply.
+func (t *replyTracker) put(requestID string, channelID string, messageIDs []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if elem, ok := t.entries[requestID]; ok {
+		t.order.Remove(elem)
+	}
+	t.entries[requestID] = t.order.PushBack(&trackedReply{
+		requestID:  requestID,
+		channelID:  channelID,
+		messageIDs: messageIDs,
+		expires:    t.now().Add(t.ttl),
+	})
+	for t.order.Len() > t.size {
+		t.removeElement(t.order.Front())
+	}
+}
+
+// get returns the reply to the request message, or false if there is none or it has expired.
+func (t *replyTracker) get(requestID string) (trackedReply, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lookup(requestID)
+}
+
+// remove forgets and returns the reply to the request message.
+func (t *replyTracker) remove(requestID string) (trackedReply, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	reply, ok := t.lookup(requestID)
+	if ok {
+		t.removeElement(t.entries[requestID])
+	}
+	return reply, ok
+}
+
+func (t *replyTracker) lookup(requestID string) (trackedReply, bool) {
+	elem, ok := t.entries[requestID]
+	if !ok {
+		return trackedReply{}, false
+	}
+	reply := elem.Value.(*trackedReply)
+	if !t.now().Before(reply.expires) {
+		t.removeElement(elem)
+		return trackedReply{}, false
+	}
+	return *reply, true
+}
+
+func (t *replyTracker) removeElement(elem *list.Element) {
+	t.order.Remove(elem)
+	delete(t.entries, elem.Value.(*trackedReply).requestID)
+}
+
+// messages returns stubs of the reply's messages, enough to edit or delete them.
+func (r trackedReply) messages() []*discordgo.Message {
+	messages := make([]*discordgo.Message, len(r.messageIDs))
+	for i, id := range r.messageIDs {
+		messages[i] = &discordgo.Message{ID: id, ChannelID: r.channelID}
+	}
+	return messages
+}
+
+// SetReplyTracking sets how many requests' replies are remembered and for how long.
+// Edits and deletions of requests that are no longer remembered leave their replies alone.
+func (b *Bot) SetReplyTracking(size int, ttl time.Duration) {
+	b.replies = newReplyTracker(size, ttl)
+}
+
+// SetDeleteOrphanedReplies sets whether replies are deleted along with their request message.
+func (b *Bot) SetDeleteOrphanedReplies(enabled bool) {
+	b.deleteOrphanedReplies = enabled
+}
+
+// track remembers the messages the target sent in reply to the request message.
+func (b *Bot) track(requestID string, target *channelTarget) {
+	if len(target.sent) == 0 {
+		return
+	}
+	ids := make([]string, len(target.sent))
+	for i, msg := range target.sent {
+		ids[i] = msg.ID
+	}
+	b.replies.put(requestID, target.channelID, ids)
+}
+
+// handleMessageUpdate answers an edited request again, editing the earlier reply in place.
+func (b *Bot) handleMessageUpdate(session *discordgo.Session, u *discordgo.MessageUpdate) {
+	// Updates without an author only change embeds, such as link previews.
+	if u.Author == nil {
+		return
+	}
+	tracked, ok := b.replies.get(u.ID)
+	if !ok || !b.shouldRespond(u.Message) {
+		return
+	}
+	ctx := orchestrator.WithTraceID(context.Background(), b.logger)
+	b.logger.InfoContext(ctx, "Responding to edited Discord message", "user", u.Author.ID, "message_id", u.ID)
+
+	req := b.newRequest(ctx, u.Message)
+	req.Edited = true
+	if tracked.channelID != u.ChannelID {
+		// The reply was sent in a thread started on the request.
+		req.ThreadID = tracked.channelID
+		req.ParentID = u.ChannelID
+	}
+	target := &channelTarget{
+		session:     session,
+		channelID:   tracked.channelID,
+		request:     u.Reference(),
+		requesterID: u.Author.ID,
+		reuse:       tracked.messages(),
+		// The earlier reply already replies to the request.
+		replied: true,
+	}
+	b.respond(ctx, req, HistoryRequest{Message: u.Message, RequesterID: u.Author.ID}, target)
+
+	// A shorter answer leaves some of the earlier reply unused.
+	b.deleteMessages(ctx, tracked.channelID, target.reuse)
+	b.track(u.ID, target)
+}
+
+// handleMessageDelete deletes the reply to a deleted request, if enabled.
+func (b *Bot) handleMessageDelete(session *discordgo.Session, d *discordgo.MessageDelete) {
+	if !b.deleteOrphanedReplies {
+		return
+	}
+	tracked, ok := b.replies.remove(d.ID)
+	if !ok {
+		return
+	}
+	ctx := orchestrator.WithTraceID(context.Background(), b.logger)
+	b.logger.InfoContext(ctx, "Deleting reply to deleted Discord message", "message_id", d.ID, "channel_id", tracked.channelID)
+	b.deleteMessages(ctx, tracked.channelID, tracked.messages())
+}
+
+func (b *Bot) deleteMessages(ctx context.Context, channelID string, messages []*discordgo.Message) {
+	for _, msg := range messages {
+		if err := b.discord.ChannelMessageDelete(channelID, msg.ID); err != nil {
+			b.logger.WarnContext(ctx, "Failed to delete reply", "error", err, "channel_id", channelID, "message_id", msg.ID)
+		}
+	}
+}
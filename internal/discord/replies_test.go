@@ -0,0 +1,46 @@
+package discord
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestReplyTracker(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newReplyTracker(2, time.Hour)
+	tracker.now = func() time.Time { return now }
+
+	tracker.put("request-1", "channel", []string{"reply-1a", "reply-1b"})
+	reply, ok := tracker.get("request-1")
+	if !ok || reply.channelID != "channel" || !slices.Equal(reply.messageIDs, []string{"reply-1a", "reply-1b"}) {
+		t.Fatalf("expected tracked reply, got %+v", reply)
+	}
+	messages := reply.messages()
+	if len(messages) != 2 || messages[1].ID != "reply-1b" || messages[1].ChannelID != "channel" {
+		t.Errorf("unexpected message stubs %+v", messages)
+	}
+
+	tracker.put("request-1", "thread", []string{"reply-1c"})
+	if reply, _ := tracker.get("request-1"); reply.channelID != "thread" || len(reply.messageIDs) != 1 {
+		t.Errorf("expected reply to be replaced, got %+v", reply)
+	}
+
+	tracker.put("request-2", "channel", []string{"reply-2"})
+	tracker.put("request-3", "channel", []string{"reply-3"})
+	if _, ok := tracker.get("request-1"); ok {
+		t.Error("expected the oldest request to be evicted past the size limit")
+	}
+
+	if _, ok := tracker.remove("request-2"); !ok {
+		t.Error("expected request-2 to be removed")
+	}
+	if _, ok := tracker.get("request-2"); ok {
+		t.Error("expected removed request to be forgotten")
+	}
+
+	now = now.Add(time.Hour)
+	if _, ok := tracker.get("request-3"); ok {
+		t.Error("expected request to expire after the TTL")
+	}
+}
@@ -0,0 +1,285 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"rsandz/bearlawyergo/internal/message"
+	"strings"
+
+	discordgo "github.com/bwmarrin/discordgo"
+)
+
+const (
+	attachmentName   = "reply.md"
+	attachmentNotice = "My answer is rather long, so I have attached it as a file."
+)
+
+// replyTarget is where the bot writes its reply to a request.
+type replyTarget interface {
+	// send writes a new message.
+	send(content string) (*discordgo.Message, error)
+	// edit replaces the content of a message written by send.
+	edit(msg *discordgo.Message, content string) (*discordgo.Message, error)
+	// attach writes a message with a file. If msg is not nil, msg is edited instead of writing a new message.
+	attach(msg *discordgo.Message, content string, file *discordgo.File) (*discordgo.Message, error)
+	// sendOutput writes a message with embeds or files. reply sends it as a reply to the request message,
+	// and ephemeral shows it only to the requester.
+	sendOutput(data *discordgo.MessageSend, reply, ephemeral bool) (*discordgo.Message, error)
+	// react adds a reaction to the request message.
+	react(emoji string) error
+	// setComponents replaces the components of a message written by the target.
+	setComponents(msg *discordgo.Message, components []discordgo.MessageComponent) (*discordgo.Message, error)
+}
+
+// errNoRequestMessage is returned for outputs that refer to a request message when there is none.
+var errNoRequestMessage = errors.New("no request message")
+
+// channelTarget replies with regular messages in a channel.
+// The first message is sent as a reply to the request message if it is in the same channel.
+type channelTarget struct {
+	session   *discordgo.Session
+	channelID string
+	// request is the message being answered. Nil if there is none.
+	request *discordgo.MessageReference
+	// requesterID is the user being answered, who is sent ephemeral outputs privately.
+	requesterID string
+	replied     bool
+	// reuse holds messages of an earlier reply to the same request. They are overwritten before new messages are sent.
+	reuse []*discordgo.Message
+	// sent records the messages written to the channel, in order.
+	sent []*discordgo.Message
+}
+
+func (t *channelTarget) send(content string) (*discordgo.Message, error) {
+	if len(t.reuse) > 0 {
+		return t.overwrite(&discordgo.MessageEdit{Content: &content})
+	}
+	return t.record(t.session.ChannelMessageSendComplex(t.channelID, &discordgo.MessageSend{
+		Content:   content,
+		Reference: t.takeReference(),
+	}))
+}
+
+// overwrite replaces the next reused message, clearing what the earlier reply attached to it.
+func (t *channelTarget) overwrite(edit *discordgo.MessageEdit) (*discordgo.Message, error) {
+	msg := t.reuse[0]
+	t.reuse = t.reuse[1:]
+	edit.ID = msg.ID
+	edit.Channel = t.channelID
+	edit.Embeds = &[]*discordgo.MessageEmbed{}
+	edit.Components = &[]discordgo.MessageComponent{}
+	edit.Attachments = &[]*discordgo.MessageAttachment{}
+	return t.record(t.session.ChannelMessageEditComplex(edit))
+}
+
+// record remembers a message sent to the channel.
+func (t *channelTarget) record(msg *discordgo.Message, err error) (*discordgo.Message, error) {
+	if err == nil {
+		t.sent = append(t.sent, msg)
+	}
+	return msg, err
+}
+
+// takeReference returns the reference for the next message, so that only the first message is a reply.
+func (t *channelTarget) takeReference() *discordgo.MessageReference {
+	if t.replied {
+		return nil
+	}
+	t.replied = true
+	return t.requestReference()
+}
+
+// requestReference returns a reference to the request message, or nil if it cannot be replied to from this channel.
+func (t *channelTarget) requestReference() *discordgo.MessageReference {
+	if t.request == nil || t.request.ChannelID != t.channelID {
+		return nil
+	}
+	return t.request
+}
+
+func (t *channelTarget) sendOutput(data *discordgo.MessageSend, reply, ephemeral bool) (*discordgo.Message, error) {
+	if ephemeral {
+		// Channel messages are visible to everyone, so ephemeral outputs go to the requester's direct messages.
+		if t.requesterID == "" {
+			return nil, fmt.Errorf("cannot send ephemeral output: %w", errNoRequestMessage)
+		}
+		dm, err := t.session.UserChannelCreate(t.requesterID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open direct message channel: %w", err)
+		}
+		return t.session.ChannelMessageSendComplex(dm.ID, data)
+	}
+	if reply {
+		data.Reference = t.requestReference()
+	}
+	return t.record(t.session.ChannelMessageSendComplex(t.channelID, data))
+}
+
+func (t *channelTarget) setComponents(msg *discordgo.Message, components []discordgo.MessageComponent) (*discordgo.Message, error) {
+	return t.session.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:         msg.ID,
+		Channel:    msg.ChannelID,
+		Components: &components,
+	})
+}
+
+func (t *channelTarget) react(emoji string) error {
+	if t.request == nil {
+		return errNoRequestMessage
+	}
+	return t.session.MessageReactionAdd(t.request.ChannelID, t.request.MessageID, emoji)
+}
+
+func (t *channelTarget) edit(msg *discordgo.Message, content string) (*discordgo.Message, error) {
+	return t.session.ChannelMessageEdit(t.channelID, msg.ID, content)
+}
+
+func (t *channelTarget) attach(msg *discordgo.Message, content string, file *discordgo.File) (*discordgo.Message, error) {
+	if msg == nil {
+		if len(t.reuse) > 0 {
+			return t.overwrite(&discordgo.MessageEdit{Content: &content, Files: []*discordgo.File{file}})
+		}
+		return t.record(t.session.ChannelMessageSendComplex(t.channelID, &discordgo.MessageSend{
+			Content:   content,
+			Files:     []*discordgo.File{file},
+			Reference: t.takeReference(),
+		}))
+	}
+	return t.session.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:      msg.ID,
+		Channel: t.channelID,
+		Content: &content,
+		Files:   []*discordgo.File{file},
+	})
+}
+
+// interactionTarget replies to a deferred interaction.
+// The first message replaces the deferred response and later messages are sent as follow-ups.
+type interactionTarget struct {
+	session     *discordgo.Session
+	interaction *discordgo.Interaction
+	responded   bool
+}
+
+func (t *interactionTarget) send(content string) (*discordgo.Message, error) {
+	if !t.responded {
+		t.responded = true
+		return t.session.InteractionResponseEdit(t.interaction, &discordgo.WebhookEdit{Content: &content})
+	}
+	return t.session.FollowupMessageCreate(t.interaction, true, &discordgo.WebhookParams{Content: content})
+}
+
+func (t *interactionTarget) edit(msg *discordgo.Message, content string) (*discordgo.Message, error) {
+	return t.session.FollowupMessageEdit(t.interaction, msg.ID, &discordgo.WebhookEdit{Content: &content})
+}
+
+func (t *interactionTarget) attach(msg *discordgo.Message, content string, file *discordgo.File) (*discordgo.Message, error) {
+	edit := &discordgo.WebhookEdit{Content: &content, Files: []*discordgo.File{file}}
+	if msg != nil {
+		return t.session.FollowupMessageEdit(t.interaction, msg.ID, edit)
+	}
+	if !t.responded {
+		t.responded = true
+		return t.session.InteractionResponseEdit(t.interaction, edit)
+	}
+	return t.session.FollowupMessageCreate(t.interaction, true, &discordgo.WebhookParams{
+		Content: content,
+		Files:   []*discordgo.File{file},
+	})
+}
+
+// sendOutput ignores reply as the interaction's response is already a reply to the command.
+// Ephemeral outputs are always sent as follow-ups, as the deferred response is visible to everyone.
+func (t *interactionTarget) sendOutput(data *discordgo.MessageSend, reply, ephemeral bool) (*discordgo.Message, error) {
+	if !t.responded && !ephemeral {
+		t.responded = true
+		return t.session.InteractionResponseEdit(t.interaction, &discordgo.WebhookEdit{
+			Content: &data.Content,
+			Embeds:  &data.Embeds,
+			Files:   data.Files,
+		})
+	}
+	params := &discordgo.WebhookParams{Content: data.Content, Embeds: data.Embeds, Files: data.Files}
+	if ephemeral {
+		params.Flags = discordgo.MessageFlagsEphemeral
+	}
+	return t.session.FollowupMessageCreate(t.interaction, true, params)
+}
+
+func (t *interactionTarget) setComponents(msg *discordgo.Message, components []discordgo.MessageComponent) (*discordgo.Message, error) {
+	return t.session.FollowupMessageEdit(t.interaction, msg.ID, &discordgo.WebhookEdit{Components: &components})
+}
+
+// react is unsupported as slash commands have no message to react to.
+func (t *interactionTarget) react(emoji string) error {
+	return errNoRequestMessage
+}
+
+// deliver writes content as a reply, replacing the streamed reply if there is one.
+// Returns the last message written, or the streamed reply if nothing was written.
+// Failures are logged rather than returned as there is no way left to tell the user.
+func (b *Bot) deliver(ctx context.Context, target replyTarget, reply *discordgo.Message, content string) *discordgo.Message {
+	last, err := b.write(target, reply, content)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "Failed to send reply", "error", err, "length", len(content))
+	}
+	return last
+}
+
+// write writes content as a reply, replacing the streamed reply if there is one.
+// Content too long for one message is split across several, or attached as a file past the attachment threshold.
+// Empty content is not written, as Discord rejects empty messages.
+// Returns the last message written, or the streamed reply if nothing was written, and the first failure.
+func (b *Bot) write(target replyTarget, reply *discordgo.Message, content string) (*discordgo.Message, error) {
+	if strings.TrimSpace(content) == "" {
+		return reply, nil
+	}
+
+	if len(content) > b.attachmentThreshold {
+		file := &discordgo.File{Name: attachmentName, ContentType: "text/markdown", Reader: strings.NewReader(content)}
+		sent, err := target.attach(reply, attachmentNotice, file)
+		if err != nil {
+			return reply, fmt.Errorf("failed to send reply attachment: %w", err)
+		}
+		return sent, nil
+	}
+
+	chunks := splitMessage(content, maxMessageLength)
+	last := reply
+	var err error
+	if reply == nil {
+		last, err = target.send(chunks[0])
+	} else if reply.Content != chunks[0] {
+		last, err = target.edit(reply, chunks[0])
+	}
+	if err != nil {
+		return reply, fmt.Errorf("failed to send chunk 1 of %d: %w", len(chunks), err)
+	}
+
+	for i, chunk := range chunks[1:] {
+		sent, err := target.send(chunk)
+		if err != nil {
+			return last, fmt.Errorf("failed to send chunk %d of %d: %w", i+2, len(chunks), err)
+		}
+		last = sent
+	}
+	return last, nil
+}
+
+// attachComponents adds the components to the message. Without a message there is nothing to attach them to,
+// so they are dropped.
+func (b *Bot) attachComponents(ctx context.Context, target replyTarget, msg *discordgo.Message, components []message.Component) {
+	if msg == nil {
+		b.logger.WarnContext(ctx, "Dropping components of a reply without a message", "components", len(components))
+		return
+	}
+	rows, err := b.components.toComponents(components)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "Failed to prepare components", "error", err)
+		return
+	}
+	if _, err := target.setComponents(msg, rows); err != nil {
+		b.logger.ErrorContext(ctx, "Failed to attach components", "error", err)
+	}
+}
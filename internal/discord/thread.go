@@ -0,0 +1,62 @@
+package discord
+
+import (
+	"context"
+	"rsandz/bearlawyergo/internal/message"
+
+	discordgo "github.com/bwmarrin/discordgo"
+)
+
+const (
+	// DefaultThreadAutoArchive is how many minutes a thread started by the bot stays open without activity.
+	DefaultThreadAutoArchive = 1440
+	// maxThreadNameLength is Discord's limit on thread names.
+	maxThreadNameLength = 100
+)
+
+// channel returns the channel from the session state, falling back to the REST API.
+func (b *Bot) channel(channelID string) (*discordgo.Channel, error) {
+	if ch, err := b.discord.State.Channel(channelID); err == nil {
+		return ch, nil
+	}
+	return b.discord.Channel(channelID)
+}
+
+// locate fills in the request's guild, channel, thread and parent IDs for the given channel.
+// If the channel cannot be resolved the request is treated as a plain channel.
+func (b *Bot) locate(ctx context.Context, req *message.Request, channelID string) {
+	req.ChannelID = channelID
+	ch, err := b.channel(channelID)
+	if err != nil {
+		b.logger.WarnContext(ctx, "Failed to resolve channel", "error", err, "channel_id", channelID)
+		return
+	}
+	req.GuildID = ch.GuildID
+	req.DirectMessage = ch.Type == discordgo.ChannelTypeDM
+	if ch.IsThread() {
+		req.ThreadID = ch.ID
+		req.ParentID = ch.ParentID
+	}
+}
+
+// ownsThread reports whether the channel is a thread started by the bot.
+func (b *Bot) ownsThread(channelID string) bool {
+	ch, err := b.channel(channelID)
+	if err != nil {
+		return false
+	}
+	return ch.IsThread() && ch.OwnerID == b.discord.State.User.ID
+}
+
+// startThread starts a thread on the message so the conversation continues inside it.
+// The thread is named after the message's normalized content.
+func (b *Bot) startThread(m *discordgo.Message, content string) (*discordgo.Channel, error) {
+	name := truncate(content, maxThreadNameLength)
+	if name == "" {
+		name = "Conversation with " + m.Author.Username
+	}
+	return b.discord.MessageThreadStartComplex(m.ChannelID, m.ID, &discordgo.ThreadStart{
+		Name:                name,
+		AutoArchiveDuration: b.threadAutoArchive,
+	})
+}
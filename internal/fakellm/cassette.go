@@ -0,0 +1,191 @@
+// Package fakellm provides language models that run without a provider: a replayer of recorded cassettes,
+// a recorder that makes them, and a scripted fake with canned replies.
+package fakellm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNoInteraction is returned when a cassette has no recorded response for a request.
+var ErrNoInteraction = errors.New("no recorded interaction matches the request")
+
+// Cassette is a recording of requests to a language model and its responses.
+type Cassette struct {
+	Interactions []Interaction `yaml:"interactions"`
+}
+
+type Interaction struct {
+	Request  []Message `yaml:"request"`
+	Response Response  `yaml:"response"`
+}
+
+// Message is a recorded message of a request.
+type Message struct {
+	Role  llms.ChatMessageType `yaml:"role"`
+	Parts []Part               `yaml:"parts"`
+}
+
+// Part is a recorded part of a message. Exactly one field is set.
+type Part struct {
+	Text     string `yaml:"text,omitempty"`
+	ImageURL string `yaml:"image_url,omitempty"`
+	// Binary identifies binary content, such as an uploaded image, by its MIME type and hash instead of storing it.
+	Binary       string        `yaml:"binary,omitempty"`
+	ToolCall     *ToolCall     `yaml:"tool_call,omitempty"`
+	ToolResponse *ToolResponse `yaml:"tool_response,omitempty"`
+}
+
+type ToolCall struct {
+	ID        string `yaml:"id"`
+	Name      string `yaml:"name"`
+	Arguments string `yaml:"arguments"`
+}
+
+type ToolResponse struct {
+	ID      string `yaml:"id"`
+	Name    string `yaml:"name"`
+	Content string `yaml:"content"`
+}
+
+// Response is the recorded first choice of a response.
+type Response struct {
+	Content    string     `yaml:"content"`
+	StopReason string     `yaml:"stop_reason,omitempty"`
+	ToolCalls  []ToolCall `yaml:"tool_calls,omitempty"`
+}
+
+// LoadCassette reads the cassette at path. A missing file is an empty cassette.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Cassette{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+
+	var cassette Cassette
+	if err := yaml.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cassette %s: %w", path, err)
+	}
+	return &cassette, nil
+}
+
+// Save writes the cassette to path, replacing the file only once it is completely written.
+func (c *Cassette) Save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cassette: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save cassette: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save cassette: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save cassette: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save cassette: %w", err)
+	}
+	return nil
+}
+
+// toMessages records the messages of a request.
+func toMessages(contents []llms.MessageContent) []Message {
+	messages := make([]Message, len(contents))
+	for i, content := range contents {
+		messages[i].Role = content.Role
+		for _, part := range content.Parts {
+			messages[i].Parts = append(messages[i].Parts, toPart(part))
+		}
+	}
+	return messages
+}
+
+func toPart(part llms.ContentPart) Part {
+	switch part := part.(type) {
+	case llms.TextContent:
+		return Part{Text: part.Text}
+	case llms.ImageURLContent:
+		return Part{ImageURL: part.URL}
+	case llms.BinaryContent:
+		sum := sha256.Sum256(part.Data)
+		return Part{Binary: part.MIMEType + " sha256:" + hex.EncodeToString(sum[:])}
+	case llms.ToolCall:
+		call := ToolCall{ID: part.ID}
+		if part.FunctionCall != nil {
+			call.Name = part.FunctionCall.Name
+			call.Arguments = part.FunctionCall.Arguments
+		}
+		return Part{ToolCall: &call}
+	case llms.ToolCallResponse:
+		return Part{ToolResponse: &ToolResponse{ID: part.ToolCallID, Name: part.Name, Content: part.Content}}
+	default:
+		return Part{Text: fmt.Sprintf("%v", part)}
+	}
+}
+
+// toResponse records the first choice of the response.
+func toResponse(resp *llms.ContentResponse) Response {
+	if len(resp.Choices) == 0 {
+		return Response{}
+	}
+	choice := resp.Choices[0]
+	recorded := Response{Content: choice.Content, StopReason: choice.StopReason}
+	for _, call := range choice.ToolCalls {
+		recorded.ToolCalls = append(recorded.ToolCalls, *toPart(call).ToolCall)
+	}
+	return recorded
+}
+
+// contentResponse returns the recorded response as the model would have.
+func (r Response) contentResponse() *llms.ContentResponse {
+	choice := &llms.ContentChoice{Content: r.Content, StopReason: r.StopReason}
+	for _, call := range r.ToolCalls {
+		choice.ToolCalls = append(choice.ToolCalls, llms.ToolCall{
+			ID:           call.ID,
+			Type:         "function",
+			FunctionCall: &llms.FunctionCall{Name: call.Name, Arguments: call.Arguments},
+		})
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{choice}}
+}
+
+// matchKey identifies a request for replay. System messages are left out so that editing the prompts does not
+// invalidate recordings, and so are the contents of tool responses, since tools such as dice are not deterministic.
+func matchKey(messages []Message) string {
+	var key strings.Builder
+	for _, msg := range messages {
+		if msg.Role == llms.ChatMessageTypeSystem {
+			continue
+		}
+		fmt.Fprintf(&key, "%s:", msg.Role)
+		for _, part := range msg.Parts {
+			switch {
+			case part.ToolCall != nil:
+				fmt.Fprintf(&key, "call(%q,%q,%q)", part.ToolCall.ID, part.ToolCall.Name, part.ToolCall.Arguments)
+			case part.ToolResponse != nil:
+				fmt.Fprintf(&key, "result(%q,%q)", part.ToolResponse.ID, part.ToolResponse.Name)
+			default:
+				fmt.Fprintf(&key, "%q%q%q", part.Text, part.ImageURL, part.Binary)
+			}
+		}
+		key.WriteString("\n")
+	}
+	return key.String()
+}
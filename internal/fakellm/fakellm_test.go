@@ -0,0 +1,201 @@
+package fakellm
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// liveModel stands in for a real provider: it calls the dice tool once, then answers.
+type liveModel struct {
+	calls int
+}
+
+func (m *liveModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	m.calls++
+	last := messages[len(messages)-1]
+	if last.Role == llms.ChatMessageTypeHuman {
+		return &llms.ContentResponse{Choices: []*llms.ContentChoice{{
+			ToolCalls: []llms.ToolCall{{ID: "call_1", Type: "function", FunctionCall: &llms.FunctionCall{Name: "roll_dice", Arguments: `{"sides":6}`}}},
+		}}}, nil
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: "The court rolled a 4.", StopReason: "stop"}}}, nil
+}
+
+func (m *liveModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+// conversation returns the messages of a dice roll: the question, then the tool call and its result if roll is set.
+func conversation(system string, roll string) []llms.MessageContent {
+	messages := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, system),
+		llms.TextParts(llms.ChatMessageTypeHuman, "Roll a die"),
+	}
+	if roll == "" {
+		return messages
+	}
+	return append(messages,
+		llms.MessageContent{Role: llms.ChatMessageTypeAI, Parts: []llms.ContentPart{
+			llms.ToolCall{ID: "call_1", Type: "function", FunctionCall: &llms.FunctionCall{Name: "roll_dice", Arguments: `{"sides":6}`}},
+		}},
+		llms.MessageContent{Role: llms.ChatMessageTypeTool, Parts: []llms.ContentPart{
+			llms.ToolCallResponse{ToolCallID: "call_1", Name: "roll_dice", Content: roll},
+		}},
+	)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dice.yaml")
+	live := &liveModel{}
+
+	recorder, err := NewRecorder(live, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recorder.GenerateContent(ctx, conversation("You are a bear", "")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recorder.GenerateContent(ctx, conversation("You are a bear", "4")); err != nil {
+		t.Fatal(err)
+	}
+
+	replayer, err := LoadReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing the system prompt or the dice roll does not stop the recording from matching.
+	resp, err := replayer.GenerateContent(ctx, conversation("You are a lawyer", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := resp.Choices[0].ToolCalls
+	if len(calls) != 1 || calls[0].ID != "call_1" || calls[0].FunctionCall.Name != "roll_dice" || calls[0].FunctionCall.Arguments != `{"sides":6}` {
+		t.Errorf("tool calls = %+v, want the recorded call", calls)
+	}
+
+	var streamed string
+	resp, err = replayer.GenerateContent(ctx, conversation("You are a lawyer", "2"), llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+		streamed += string(chunk)
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Choices[0].Content != "The court rolled a 4." || streamed != "The court rolled a 4." {
+		t.Errorf("content = %q, streamed %q, want the recorded answer", resp.Choices[0].Content, streamed)
+	}
+	if live.calls != 2 {
+		t.Errorf("live calls = %d, want 2", live.calls)
+	}
+
+	_, err = replayer.GenerateContent(ctx, []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "Flip a coin")})
+	if !errors.Is(err, ErrNoInteraction) {
+		t.Errorf("error = %v, want %v", err, ErrNoInteraction)
+	}
+}
+
+func TestReplayOrder(t *testing.T) {
+	question := []Message{{Role: llms.ChatMessageTypeHuman, Parts: []Part{{Text: "Verdict?"}}}}
+	replayer := NewReplayer(&Cassette{Interactions: []Interaction{
+		{Request: question, Response: Response{Content: "Guilty"}},
+		{Request: question, Response: Response{Content: "Not guilty"}},
+	}})
+
+	for _, want := range []string{"Guilty", "Not guilty", "Not guilty"} {
+		reply, err := replayer.Call(context.Background(), "Verdict?")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reply != want {
+			t.Errorf("reply = %q, want %q", reply, want)
+		}
+	}
+}
+
+func TestScripted(t *testing.T) {
+	scripted, err := LoadScripted(filepath.Join("testdata", "script.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		prompt string
+		want   string
+	}{
+		{prompt: "Hello there", want: "Greetings. Court is in session."},
+		{prompt: "OBJECTION!", want: "Overruled. Purely hypothetically, of course."},
+		{prompt: "What is a tort?", want: "The bear has considered your request and finds it acceptable."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prompt, func(t *testing.T) {
+			reply, err := scripted.Call(context.Background(), tt.prompt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reply != tt.want {
+				t.Errorf("reply = %q, want %q", reply, tt.want)
+			}
+		})
+	}
+
+	strict, err := NewScripted([]Rule{{Pattern: "^hello$", Reply: "Hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := strict.Call(context.Background(), "goodbye"); !errors.Is(err, ErrNoReply) {
+		t.Errorf("error = %v, want %v", err, ErrNoReply)
+	}
+	if _, err := NewScripted([]Rule{{Pattern: "("}}); err == nil {
+		t.Error("NewScripted() error = nil, want an invalid pattern error")
+	}
+}
+
+func TestScriptedToolCalls(t *testing.T) {
+	scripted, err := NewScripted([]Rule{
+		{Pattern: "^sunny$", Reply: "Court is adjourned for a picnic."},
+		{Pattern: "weather", Reply: "Let me check.", ToolCalls: []ToolCall{{ID: "call-1", Name: "weather", Arguments: "{}"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var streamed []string
+	streaming := llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+		streamed = append(streamed, string(chunk))
+		return nil
+	})
+	question := []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "What is the weather?")}
+	resp, err := scripted.GenerateContent(context.Background(), question, streaming)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := resp.Choices[0].ToolCalls
+	if len(calls) != 1 || calls[0].FunctionCall.Name != "weather" {
+		t.Fatalf("tool calls = %+v, want the weather tool", calls)
+	}
+	want := []string{"Let me check.", `[{"id":"call-1","type":"function","function":{"name":"weather","arguments":"{}"}}]`}
+	if !slices.Equal(streamed, want) {
+		t.Errorf("streamed = %q, want %q", streamed, want)
+	}
+
+	// The answer to the tool result is matched on the result.
+	result := llms.MessageContent{Role: llms.ChatMessageTypeTool, Parts: []llms.ContentPart{llms.ToolCallResponse{ToolCallID: "call-1", Name: "weather", Content: "sunny"}}}
+	resp, err = scripted.GenerateContent(context.Background(), append(question, result))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Choices[0].Content; got != "Court is adjourned for a picnic." {
+		t.Errorf("reply = %q, want the answer to the tool result", got)
+	}
+
+	if recorded := scripted.Calls(); len(recorded) != 2 || recorded[0].Options.StreamingFunc == nil || len(recorded[1].Messages) != 2 {
+		t.Errorf("calls = %+v, want both requests recorded", recorded)
+	}
+}
@@ -0,0 +1,48 @@
+package fakellm
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Recorder is a model that passes requests through to another model and saves each successful exchange
+// to a cassette file, adding to what the file already holds.
+type Recorder struct {
+	model llms.Model
+	path  string
+
+	mu       sync.Mutex
+	cassette *Cassette
+}
+
+// Creates a new recorder of the model's exchanges into the cassette at path.
+func NewRecorder(model llms.Model, path string) (*Recorder, error) {
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{model: model, path: path, cassette: cassette}, nil
+}
+
+func (r *Recorder) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	// Record the request as sent, before the caller can append to it.
+	request := toMessages(messages)
+	resp, err := r.model.GenerateContent(ctx, messages, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{Request: request, Response: toResponse(resp)})
+	if err := r.cassette.Save(r.path); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Recorder) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, r, prompt, options...)
+}
@@ -0,0 +1,126 @@
+package fakellm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Replayer is a model that answers with the responses recorded in a cassette.
+// Recordings are used in order, so a request made twice gets the second recording the second time.
+// Once the matching recordings run out, the last one is repeated.
+type Replayer struct {
+	mu sync.Mutex
+	// recordings are the cassette's responses by request, in recorded order.
+	recordings map[string][]Response
+	// used counts the responses replayed for each request.
+	used map[string]int
+}
+
+// Creates a new replayer of the cassette.
+func NewReplayer(cassette *Cassette) *Replayer {
+	r := &Replayer{
+		recordings: make(map[string][]Response),
+		used:       make(map[string]int),
+	}
+	for _, interaction := range cassette.Interactions {
+		key := matchKey(interaction.Request)
+		r.recordings[key] = append(r.recordings[key], interaction.Response)
+	}
+	return r
+}
+
+// LoadReplayer creates a replayer of the cassette at path.
+func LoadReplayer(path string) (*Replayer, error) {
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayer(cassette), nil
+}
+
+func (r *Replayer) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	key := matchKey(toMessages(messages))
+	r.mu.Lock()
+	responses := r.recordings[key]
+	if len(responses) == 0 {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrNoInteraction, lastText(messages))
+	}
+	response := responses[min(r.used[key], len(responses)-1)]
+	r.used[key]++
+	r.mu.Unlock()
+
+	return respond(ctx, response, options)
+}
+
+func (r *Replayer) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, r, prompt, options...)
+}
+
+// respond returns the response, streaming its content in one chunk if the caller asked for streaming.
+// Tool calls are then streamed as JSON, as langchaingo's OpenAI client does, so streaming callers see the
+// same chunks as they would from the default provider.
+func respond(ctx context.Context, response Response, options []llms.CallOption) (*llms.ContentResponse, error) {
+	var opts llms.CallOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	result := response.contentResponse()
+	if opts.StreamingFunc == nil {
+		return result, nil
+	}
+	if response.Content != "" {
+		if err := opts.StreamingFunc(ctx, []byte(response.Content)); err != nil {
+			return nil, err
+		}
+	}
+	if len(response.ToolCalls) > 0 {
+		chunk, err := json.Marshal(streamedToolCalls(response.ToolCalls))
+		if err != nil {
+			return nil, fmt.Errorf("failed to stream tool calls: %w", err)
+		}
+		if err := opts.StreamingFunc(ctx, chunk); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// streamedToolCall is a tool call as langchaingo's OpenAI client streams it.
+type streamedToolCall struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+func streamedToolCalls(calls []ToolCall) []streamedToolCall {
+	streamed := make([]streamedToolCall, len(calls))
+	for i, call := range calls {
+		streamed[i].ID = call.ID
+		streamed[i].Type = "function"
+		streamed[i].Function.Name = call.Name
+		streamed[i].Function.Arguments = call.Arguments
+	}
+	return streamed
+}
+
+// lastText returns the text of the last message, to say which request could not be answered.
+func lastText(messages []llms.MessageContent) string {
+	if len(messages) == 0 {
+		return ""
+	}
+	var text string
+	for _, part := range messages[len(messages)-1].Parts {
+		if part, ok := part.(llms.TextContent); ok {
+			text += part.Text
+		}
+	}
+	return fmt.Sprintf("%q", text)
+}
@@ -0,0 +1,132 @@
+package fakellm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sync"
+
+	"github.com/tmc/langchaingo/llms"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNoReply is returned when no rule of a script matches a request.
+var ErrNoReply = errors.New("no scripted reply matches the request")
+
+// Rule is a canned reply to requests whose last user message matches Pattern.
+// Requests that end with tool results are matched on the results instead, so a script can answer them.
+type Rule struct {
+	// Pattern is a regular expression. An empty pattern matches every request.
+	Pattern string `yaml:"match"`
+	Reply   string `yaml:"reply"`
+	// ToolCalls are requested along with the reply.
+	ToolCalls []ToolCall `yaml:"tool_calls,omitempty"`
+}
+
+// Scripted is a model that answers with the reply of the first rule matching the last user message.
+type Scripted struct {
+	rules    []Rule
+	patterns []*regexp.Regexp
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Call is a request made to a scripted model.
+type Call struct {
+	Messages []llms.MessageContent
+	Options  llms.CallOptions
+}
+
+// Creates a new scripted model with the rules, tried in order.
+func NewScripted(rules []Rule) (*Scripted, error) {
+	s := &Scripted{rules: rules}
+	for _, rule := range rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", rule.Pattern, err)
+		}
+		s.patterns = append(s.patterns, pattern)
+	}
+	return s, nil
+}
+
+// LoadScripted creates a scripted model from the YAML file at path, which holds a list of rules under "replies".
+func LoadScripted(path string) (*Scripted, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+	var script struct {
+		Replies []Rule `yaml:"replies"`
+	}
+	if err := yaml.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal script %s: %w", path, err)
+	}
+	return NewScripted(script.Replies)
+}
+
+func (s *Scripted) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	call := Call{Messages: messages}
+	for _, option := range options {
+		option(&call.Options)
+	}
+	s.mu.Lock()
+	s.calls = append(s.calls, call)
+	s.mu.Unlock()
+
+	text := matchText(messages)
+	for i, pattern := range s.patterns {
+		if pattern.MatchString(text) {
+			rule := s.rules[i]
+			return respond(ctx, Response{Content: rule.Reply, StopReason: "stop", ToolCalls: rule.ToolCalls}, options)
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrNoReply, text)
+}
+
+// Calls returns the requests made to the model so far, oldest first.
+func (s *Scripted) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.calls)
+}
+
+func (s *Scripted) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, s, prompt, options...)
+}
+
+// matchText returns the text rules are matched against: the tool results if the request ends with them,
+// otherwise the last user message.
+func matchText(messages []llms.MessageContent) string {
+	if len(messages) == 0 || messages[len(messages)-1].Role != llms.ChatMessageTypeTool {
+		return lastUserText(messages)
+	}
+	var text string
+	for _, part := range messages[len(messages)-1].Parts {
+		if part, ok := part.(llms.ToolCallResponse); ok {
+			text += part.Content
+		}
+	}
+	return text
+}
+
+// lastUserText returns the text of the last message from the user.
+func lastUserText(messages []llms.MessageContent) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != llms.ChatMessageTypeHuman {
+			continue
+		}
+		var text string
+		for _, part := range messages[i].Parts {
+			if part, ok := part.(llms.TextContent); ok {
+				text += part.Text
+			}
+		}
+		return text
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"rsandz/bearlawyergo/internal/message"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// expandAttachments returns the message with its text attachments read into the content, and the
+// content parts for its images, which vision models read alongside the text.
+// Attachments that cannot be loaded or are of an unsupported type are skipped.
+func (h *LLMHandler) expandAttachments(ctx context.Context, msg message.Message) (message.Message, []llms.ContentPart) {
+	if len(msg.Attachments) == 0 {
+		return msg, nil
+	}
+
+	var content strings.Builder
+	content.WriteString(msg.Content)
+	var images []llms.ContentPart
+	for _, attachment := range msg.Attachments {
+		switch {
+		case attachment.IsImage():
+			part, err := imagePart(ctx, attachment)
+			if err != nil {
+				h.logger.WarnContext(ctx, "Skipping image attachment", "error", err, "attachment", attachment.Name)
+				continue
+			}
+			images = append(images, part)
+		case attachment.IsText():
+			if attachment.Load == nil {
+				h.logger.WarnContext(ctx, "Skipping text attachment that cannot be loaded", "attachment", attachment.Name)
+				continue
+			}
+			data, err := attachment.Load(ctx)
+			if err != nil {
+				h.logger.WarnContext(ctx, "Skipping text attachment", "error", err, "attachment", attachment.Name)
+				continue
+			}
+			fmt.Fprintf(&content, "\n\nAttached file %s:\n```\n%s\n```", attachment.Name, strings.TrimRight(string(data), "\n"))
+		default:
+			h.logger.InfoContext(ctx, "Skipping unsupported attachment", "attachment", attachment.Name, "content_type", attachment.ContentType)
+		}
+	}
+
+	msg.Content = strings.TrimSpace(content.String())
+	return msg, images
+}
+
+// imagePart refers the model to the image by URL, or sends the image itself if it has no URL.
+func imagePart(ctx context.Context, attachment message.Attachment) (llms.ContentPart, error) {
+	if attachment.URL != "" {
+		return llms.ImageURLContent{URL: attachment.URL}, nil
+	}
+	if attachment.Load == nil {
+		return nil, fmt.Errorf("image has neither a URL nor a loader")
+	}
+	data, err := attachment.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load image: %w", err)
+	}
+	return llms.BinaryContent{MIMEType: attachment.ContentType, Data: data}, nil
+}
@@ -0,0 +1,64 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"rsandz/bearlawyergo/internal/message"
+)
+
+// Actions of the follow-up buttons attached to answers sent over transports that support components.
+const (
+	RegenerateAction = "regenerate"
+	ShorterAction    = "shorter"
+	ExplainAction    = "explain"
+)
+
+// followUpInstructions are sent as the user's next message to rework the previous answer.
+var followUpInstructions = map[string]string{
+	ShorterAction: "Please answer my last question again, more briefly.",
+	ExplainAction: "Please explain your last answer in more detail.",
+}
+
+// followUps returns the buttons that let the user rework the answer to the question.
+func followUps(question string, answer string) []message.Component {
+	state := map[string]string{"question": question, "answer": answer}
+	return []message.Component{
+		message.NewButton("Regenerate", RegenerateAction, state),
+		message.NewButton("Shorter", ShorterAction, state),
+		message.NewButton("Explain more", ExplainAction, state),
+	}
+}
+
+// HandleInteraction reworks an earlier answer when one of its follow-up buttons is pressed.
+// Regenerate asks the question again; the other actions continue the conversation from the answer.
+func (h *LLMHandler) HandleInteraction(ctx context.Context, interaction *message.Interaction, response *message.Response) error {
+	req := interaction.Request
+	action := interaction.Component.Action
+	question := interaction.Component.State["question"]
+	answer := interaction.Component.State["answer"]
+	h.logger.InfoContext(ctx, "LLMHandler processing follow-up", "action", action)
+
+	history := req.History
+	latest := message.Message{User: req.RequestMessage.User, Content: question, Role: message.UserRole}
+	if action != RegenerateAction {
+		instruction, ok := followUpInstructions[action]
+		if !ok {
+			return fmt.Errorf("unknown follow-up action %q", action)
+		}
+		history = append(history[:len(history):len(history)], latest, message.Message{Content: answer, Role: message.BotRole})
+		latest = message.Message{User: req.RequestMessage.User, Content: instruction, Role: message.UserRole}
+	}
+
+	messages := h.window.Build(ctx, h.systemPrompt(ctx, req), req.Summary, history, latest)
+	completion, err := h.inferCompletion(ctx, messages, response.Stream)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Failed to generate follow-up", "error", err, "action", action)
+		return fmt.Errorf("failed to generate completion: %w", err)
+	}
+
+	response.ResponseMessage = message.Message{Content: completion}
+	if req.Capabilities.Components {
+		response.AddComponent(followUps(question, completion)...)
+	}
+	return nil
+}
@@ -1,42 +1,66 @@
 package llm
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
-	"rsandz/bearlawyergo/internal/adapter/langchain"
 	"rsandz/bearlawyergo/internal/config"
+	"rsandz/bearlawyergo/internal/contextwindow"
 	"rsandz/bearlawyergo/internal/message"
+	"rsandz/bearlawyergo/internal/tool"
+	"strings"
 
 	"github.com/tmc/langchaingo/llms"
 )
 
+// MaxToolIterations bounds how many rounds of tool calls the model may make for one message.
+const MaxToolIterations = 5
+
+var ErrMaxToolIterations = errors.New("model exceeded maximum tool iterations")
+
 type LLMHandler struct {
-	llm          llms.Model
-	logger       *slog.Logger
-	systemPrompt string
+	llm     llms.Model
+	tools   *tool.Registry
+	window  *contextwindow.Builder
+	logger  *slog.Logger
+	prompts *config.Prompts
 }
 
-func NewLLMHandler(llm llms.Model, logger *slog.Logger) (*LLMHandler, error) {
+// Creates a new LLM handler.
+// tools may be nil if the model should not call any tools.
+// window may be nil to send the full history without a token budget.
+func NewLLMHandler(llm llms.Model, tools *tool.Registry, window *contextwindow.Builder, logger *slog.Logger) (*LLMHandler, error) {
 	prompts, err := config.LoadPrompts()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load prompts: %w", err)
 	}
 
+	if window == nil {
+		window = contextwindow.NewBuilder(contextwindow.ApproximateCounter{}, 0, logger)
+	}
+
 	return &LLMHandler{
-		llm:          llm,
-		logger:       logger,
-		systemPrompt: prompts.SystemPrompt,
+		llm:     llm,
+		tools:   tools,
+		window:  window,
+		logger:  logger,
+		prompts: prompts,
 	}, nil
 }
 
 func (h *LLMHandler) Handle(ctx context.Context, msg *message.Request, response *message.Response) error {
 	h.logger.InfoContext(ctx, "LLMHandler processing message")
 
-	messages := h.buildContextWindow(msg.RequestMessage.Content, msg.History)
+	latest, images := h.expandAttachments(ctx, msg.RequestMessage)
+	messages := h.window.Build(ctx, h.systemPrompt(ctx, msg), msg.Summary, msg.History, latest)
+	// Images are not counted against the token budget; the latest message is always kept.
+	last := &messages[len(messages)-1]
+	last.Parts = append(last.Parts, images...)
 
-	completion, err := h.inferCompletion(ctx, messages)
+	completion, err := h.inferCompletion(ctx, messages, response.Stream)
 	if err != nil {
 		h.logger.ErrorContext(ctx, "Failed to generate completion", "error", err)
 		return fmt.Errorf("failed to generate completion: %w", err)
@@ -46,6 +70,9 @@ func (h *LLMHandler) Handle(ctx context.Context, msg *message.Request, response
 	response.ResponseMessage = message.Message{
 		Content: completion,
 	}
+	if msg.Capabilities.Components {
+		response.AddComponent(followUps(msg.RequestMessage.Content, completion)...)
+	}
 	return nil
 }
 
@@ -53,34 +80,120 @@ func (h *LLMHandler) CanHandle(ctx context.Context, msg *message.Request) bool {
 	return true
 }
 
-func (h *LLMHandler) buildContextWindow(userInput string, history []message.Message) []llms.MessageContent {
-	messages := []llms.MessageContent{
-		{
-			Role:  llms.ChatMessageTypeSystem,
-			Parts: []llms.ContentPart{llms.TextContent{Text: h.systemPrompt}},
-		},
+// systemPrompt returns the prompt of the requested persona, falling back to the default persona if it is unknown.
+// Direct messages without a persona use the direct message prompt.
+func (h *LLMHandler) systemPrompt(ctx context.Context, msg *message.Request) string {
+	if msg.DirectMessage && msg.Persona == "" && h.prompts.DMPrompt != "" {
+		return h.prompts.DMPrompt
+	}
+	prompt, ok := h.prompts.PersonaPrompt(msg.Persona)
+	if !ok {
+		h.logger.WarnContext(ctx, "Unknown persona requested, using default", "persona", msg.Persona)
+		prompt, _ = h.prompts.PersonaPrompt("")
 	}
+	return prompt
+}
 
-	messages = append(messages, langchain.ToLLMMessages(history)...)
+// inferCompletion generates a completion for the messages, running any tools the model calls.
+// If stream is not nil, text chunks are sent to it as the model produces them. Tool call deltas are not.
+// Text the model writes before calling tools is kept at the start of the completion, as the stream showed it.
+func (h *LLMHandler) inferCompletion(ctx context.Context, messages []llms.MessageContent, stream *message.Stream) (string, error) {
+	var options []llms.CallOption
+	if stream != nil {
+		options = append(options, llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+			if len(chunk) == 0 || isToolCallChunk(chunk) {
+				return nil
+			}
+			return stream.Send(ctx, string(chunk))
+		}))
+	}
+	if h.tools != nil && h.tools.Len() > 0 {
+		options = append(options, llms.WithTools(h.tools.Definitions()))
+	}
 
-	messages = append(messages, llms.MessageContent{
-		Role:  llms.ChatMessageTypeHuman,
-		Parts: []llms.ContentPart{llms.TextContent{Text: userInput}},
-	})
+	var preamble strings.Builder
+	for range MaxToolIterations {
+		resp, err := h.llm.GenerateContent(ctx, messages, options...)
+		if err != nil {
+			return "", err
+		}
+
+		choices := resp.Choices
+		if len(choices) < 1 {
+			return "", errors.New("empty response from model")
+		}
+		c1 := choices[0]
+		if len(c1.ToolCalls) == 0 {
+			return preamble.String() + c1.Content, nil
+		}
+
+		if c1.Content != "" {
+			preamble.WriteString(c1.Content + preambleSeparator)
+			if stream != nil {
+				if err := stream.Send(ctx, preambleSeparator); err != nil {
+					return "", err
+				}
+			}
+		}
+		messages = append(messages, h.callTools(ctx, c1.ToolCalls)...)
+	}
 
-	return messages
+	h.logger.WarnContext(ctx, "Model exceeded maximum tool iterations", "max_iterations", MaxToolIterations)
+	return "", ErrMaxToolIterations
 }
 
-func (h *LLMHandler) inferCompletion(ctx context.Context, messages []llms.MessageContent) (string, error) {
-	resp, err := h.llm.GenerateContent(ctx, messages)
-	if err != nil {
-		return "", err
+// preambleSeparator separates text the model wrote before calling tools from what it writes after.
+const preambleSeparator = "\n\n"
+
+// isToolCallChunk reports whether a streamed chunk is a tool or function call delta rather than text.
+// langchaingo's OpenAI client streams these deltas as JSON: a list of tool calls, or a single function call.
+func isToolCallChunk(chunk []byte) bool {
+	trimmed := bytes.TrimSpace(chunk)
+	if len(trimmed) == 0 || (trimmed[0] != '[' && trimmed[0] != '{') || !json.Valid(trimmed) {
+		return false
 	}
+	var calls []struct {
+		Function *json.RawMessage `json:"function"`
+	}
+	if err := json.Unmarshal(trimmed, &calls); err == nil {
+		return len(calls) > 0 && calls[0].Function != nil
+	}
+	var call struct {
+		Name      *string `json:"name"`
+		Arguments *string `json:"arguments"`
+	}
+	return json.Unmarshal(trimmed, &call) == nil && call.Name != nil && call.Arguments != nil
+}
 
-	choices := resp.Choices
-	if len(choices) < 1 {
-		return "", errors.New("empty response from model")
+// callTools executes the tool calls and returns the model's request followed by the tool results.
+// Tool failures are reported back to the model rather than failing the request.
+func (h *LLMHandler) callTools(ctx context.Context, calls []llms.ToolCall) []llms.MessageContent {
+	request := llms.MessageContent{Role: llms.ChatMessageTypeAI}
+	var results []llms.MessageContent
+	for _, call := range calls {
+		request.Parts = append(request.Parts, call)
+		if call.FunctionCall == nil {
+			continue
+		}
+
+		name := call.FunctionCall.Name
+		h.logger.InfoContext(ctx, "Calling tool", "tool", name, "tool_call_id", call.ID, "arguments", call.FunctionCall.Arguments)
+		result, err := h.tools.Execute(ctx, name, call.FunctionCall.Arguments)
+		if err != nil {
+			h.logger.WarnContext(ctx, "Tool call failed", "tool", name, "tool_call_id", call.ID, "error", err)
+			result = fmt.Sprintf("Error: %v", err)
+		} else {
+			h.logger.InfoContext(ctx, "Tool call completed", "tool", name, "tool_call_id", call.ID)
+		}
+
+		results = append(results, llms.MessageContent{
+			Role: llms.ChatMessageTypeTool,
+			Parts: []llms.ContentPart{llms.ToolCallResponse{
+				ToolCallID: call.ID,
+				Name:       name,
+				Content:    result,
+			}},
+		})
 	}
-	c1 := choices[0]
-	return c1.Content, nil
+	return append([]llms.MessageContent{request}, results...)
 }
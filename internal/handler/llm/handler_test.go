@@ -5,7 +5,10 @@ import (
 	"errors"
 	"testing"
 
+	"rsandz/bearlawyergo/internal/config"
+	"rsandz/bearlawyergo/internal/fakellm"
 	"rsandz/bearlawyergo/internal/message"
+	"rsandz/bearlawyergo/internal/tool"
 
 	"io"
 	"log/slog"
@@ -13,70 +16,42 @@ import (
 	"github.com/tmc/langchaingo/llms"
 )
 
-type mockLLM struct {
-	GenerateContentFunc func(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error)
-	CallFunc            func(ctx context.Context, prompt string, options ...llms.CallOption) (string, error)
-}
-
-func (m *mockLLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
-	if m.GenerateContentFunc != nil {
-		return m.GenerateContentFunc(ctx, messages, options...)
-	}
-	// Default behavior
-	return &llms.ContentResponse{
-		Choices: []*llms.ContentChoice{
-			{Content: "mock response"},
-		},
-	}, nil
-}
-
-func (m *mockLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
-	if m.CallFunc != nil {
-		return m.CallFunc(ctx, prompt, options...)
+// newScripted returns a scripted model that answers with the rules.
+func newScripted(t *testing.T, rules ...fakellm.Rule) *fakellm.Scripted {
+	t.Helper()
+	model, err := fakellm.NewScripted(rules)
+	if err != nil {
+		t.Fatalf("NewScripted failed: %v", err)
 	}
-	return "mock response", nil
+	return model
 }
 
 func TestLLMHandler_Handle(t *testing.T) {
 	tests := []struct {
 		name         string
 		inputContent string
-		mockResponse string
-		mockError    error
+		rules        []fakellm.Rule
 		expectedResp string
 		expectError  bool
 	}{
 		{
 			name:         "Success",
 			inputContent: "Hello",
-			mockResponse: "I am a bear lawyer",
+			rules:        []fakellm.Rule{{Pattern: "^Hello$", Reply: "I am a bear lawyer"}},
 			expectedResp: "I am a bear lawyer",
 		},
 		{
 			name:         "LLM Error",
 			inputContent: "Hello",
-			mockError:    errors.New("llm error"),
+			rules:        []fakellm.Rule{{Pattern: "^Goodbye$", Reply: "Farewell"}},
 			expectError:  true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mock := &mockLLM{
-				GenerateContentFunc: func(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
-					if tt.mockError != nil {
-						return nil, tt.mockError
-					}
-					return &llms.ContentResponse{
-						Choices: []*llms.ContentChoice{
-							{Content: tt.mockResponse},
-						},
-					}, nil
-				},
-			}
-
 			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
-			h, err := NewLLMHandler(mock, logger)
+			h, err := NewLLMHandler(newScripted(t, tt.rules...), nil, nil, logger)
 			if err != nil {
 				t.Fatalf("NewLLMHandler failed: %v", err)
 			}
@@ -85,8 +60,8 @@ func TestLLMHandler_Handle(t *testing.T) {
 			err = h.Handle(context.Background(), &message.Request{RequestMessage: message.Message{Content: tt.inputContent}}, resp)
 
 			if tt.expectError {
-				if err == nil {
-					t.Error("expected error but got none")
+				if !errors.Is(err, fakellm.ErrNoReply) {
+					t.Errorf("expected model error, got %v", err)
 				}
 				return
 			}
@@ -103,10 +78,352 @@ func TestLLMHandler_Handle(t *testing.T) {
 	}
 }
 
+func TestLLMHandler_Handle_FollowUps(t *testing.T) {
+	tests := []struct {
+		name       string
+		components bool
+		expected   int
+	}{
+		{name: "Components supported", components: true, expected: 3},
+		{name: "Components not supported", components: false, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			h, err := NewLLMHandler(newScripted(t, fakellm.Rule{Reply: "A civil wrong."}), nil, nil, logger)
+			if err != nil {
+				t.Fatalf("NewLLMHandler failed: %v", err)
+			}
+
+			req := &message.Request{
+				RequestMessage: message.Message{Content: "What is a tort?"},
+				Capabilities:   message.Capabilities{Components: tt.components},
+			}
+			response := &message.Response{}
+			if err := h.Handle(context.Background(), req, response); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(response.Components) != tt.expected {
+				t.Errorf("expected %d follow-up buttons, got %+v", tt.expected, response.Components)
+			}
+		})
+	}
+}
+
 func TestLLMHandler_CanHandle(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
-	h, _ := NewLLMHandler(&mockLLM{}, logger)
+	h, _ := NewLLMHandler(newScripted(t), nil, nil, logger)
 	if !h.CanHandle(context.Background(), &message.Request{RequestMessage: message.Message{Content: "Hello"}}) {
 		t.Error("CanHandle should always return true")
 	}
 }
+
+func TestLLMHandler_Handle_Streaming(t *testing.T) {
+	model := newScripted(t, fakellm.Rule{Reply: "I am a bear lawyer"})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	h, err := NewLLMHandler(model, nil, nil, logger)
+	if err != nil {
+		t.Fatalf("NewLLMHandler failed: %v", err)
+	}
+
+	stream := message.NewStream(3)
+	resp := &message.Response{Stream: stream}
+	if err := h.Handle(context.Background(), &message.Request{RequestMessage: message.Message{Content: "Hello"}}, resp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stream.Close()
+
+	if calls := model.Calls(); len(calls) != 1 || calls[0].Options.StreamingFunc == nil {
+		t.Fatal("expected streaming func to be set")
+	}
+	var streamed string
+	for chunk := range stream.Chunks() {
+		streamed += chunk
+	}
+	if streamed != "I am a bear lawyer" {
+		t.Errorf("expected streamed content %q, got %q", "I am a bear lawyer", streamed)
+	}
+	if resp.ResponseMessage.Content != "I am a bear lawyer" {
+		t.Errorf("expected content %q, got %q", "I am a bear lawyer", resp.ResponseMessage.Content)
+	}
+}
+
+type echoTool struct{}
+
+func (t *echoTool) Name() string               { return "echo" }
+func (t *echoTool) Description() string        { return "Echoes the arguments" }
+func (t *echoTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+func (t *echoTool) Execute(ctx context.Context, args string) (string, error) {
+	return "echo: " + args, nil
+}
+
+// echoCall asks for the echo tool.
+var echoCall = fakellm.ToolCall{ID: "call-1", Name: "echo", Arguments: `{"text":"hi"}`}
+
+func TestLLMHandler_Handle_ToolCalls(t *testing.T) {
+	tests := []struct {
+		name         string
+		rules        []fakellm.Rule
+		expectedResp string
+		expectedErr  error
+	}{
+		{
+			name: "Tool result returned to model",
+			rules: []fakellm.Rule{
+				// Tool results are matched before the question, which stays the last user message.
+				{Pattern: "^echo: ", Reply: "The tool said hi."},
+				{Pattern: "Hello", ToolCalls: []fakellm.ToolCall{echoCall}},
+			},
+			expectedResp: "The tool said hi.",
+		},
+		{
+			name:        "Max iterations",
+			rules:       []fakellm.Rule{{ToolCalls: []fakellm.ToolCall{echoCall}}},
+			expectedErr: ErrMaxToolIterations,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := newScripted(t, tt.rules...)
+			registry, err := tool.NewRegistry(&echoTool{})
+			if err != nil {
+				t.Fatalf("NewRegistry failed: %v", err)
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			h, err := NewLLMHandler(model, registry, nil, logger)
+			if err != nil {
+				t.Fatalf("NewLLMHandler failed: %v", err)
+			}
+
+			resp := &message.Response{}
+			err = h.Handle(context.Background(), &message.Request{RequestMessage: message.Message{Content: "Hello"}}, resp)
+			for _, call := range model.Calls() {
+				if len(call.Options.Tools) != 1 {
+					t.Fatalf("expected 1 tool definition, got %d", len(call.Options.Tools))
+				}
+			}
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("expected error %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.ResponseMessage.Content != tt.expectedResp {
+				t.Errorf("expected content %q, got %q", tt.expectedResp, resp.ResponseMessage.Content)
+			}
+			calls := model.Calls()
+			last := calls[len(calls)-1].Messages
+			if result := last[len(last)-1]; result.Role != llms.ChatMessageTypeTool || result.Parts[0].(llms.ToolCallResponse).Content != `echo: {"text":"hi"}` {
+				t.Errorf("expected tool result to be returned to the model, got %+v", result)
+			}
+		})
+	}
+}
+
+func TestLLMHandler_Handle_Persona(t *testing.T) {
+	prompts, err := config.LoadPrompts()
+	if err != nil {
+		t.Fatalf("LoadPrompts failed: %v", err)
+	}
+	judgePrompt, ok := prompts.PersonaPrompt("judge")
+	if !ok {
+		t.Fatal("expected judge persona to be configured")
+	}
+
+	tests := []struct {
+		name           string
+		persona        string
+		directMessage  bool
+		expectedPrompt string
+	}{
+		{name: "Default", persona: "", expectedPrompt: prompts.SystemPrompt},
+		{name: "Known persona", persona: "judge", expectedPrompt: judgePrompt},
+		{name: "Unknown persona", persona: "astronaut", expectedPrompt: prompts.SystemPrompt},
+		{name: "Direct message", persona: "", directMessage: true, expectedPrompt: prompts.DMPrompt},
+		{name: "Direct message with persona", persona: "judge", directMessage: true, expectedPrompt: judgePrompt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := newScripted(t, fakellm.Rule{Reply: "ok"})
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			h, err := NewLLMHandler(model, nil, nil, logger)
+			if err != nil {
+				t.Fatalf("NewLLMHandler failed: %v", err)
+			}
+
+			req := &message.Request{RequestMessage: message.Message{Content: "Hello"}, Persona: tt.persona, DirectMessage: tt.directMessage}
+			if err := h.Handle(context.Background(), req, &message.Response{}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if systemPrompt := model.Calls()[0].Messages[0].Parts[0].(llms.TextContent).Text; systemPrompt != tt.expectedPrompt {
+				t.Errorf("expected system prompt %q, got %q", tt.expectedPrompt, systemPrompt)
+			}
+		})
+	}
+}
+
+func TestLLMHandler_Handle_Attachments(t *testing.T) {
+	model := newScripted(t, fakellm.Rule{Reply: "ok"})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	h, err := NewLLMHandler(model, nil, nil, logger)
+	if err != nil {
+		t.Fatalf("NewLLMHandler failed: %v", err)
+	}
+
+	load := func(data string) func(ctx context.Context) ([]byte, error) {
+		return func(ctx context.Context) ([]byte, error) { return []byte(data), nil }
+	}
+	req := &message.Request{RequestMessage: message.Message{
+		Content: "What went wrong?",
+		Attachments: []message.Attachment{
+			{Name: "server.log", ContentType: "application/octet-stream", Load: load("panic: bear in the den\n")},
+			{Name: "den.png", ContentType: "image/png", URL: "https://example.com/den.png"},
+			{Name: "scan.jpg", ContentType: "image/jpeg", Load: load("jpeg")},
+			{Name: "archive.zip", ContentType: "application/zip", Load: load("zip")},
+		},
+	}}
+	if err := h.Handle(context.Background(), req, &message.Response{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	messages := model.Calls()[0].Messages
+	latest := messages[len(messages)-1]
+	if len(latest.Parts) != 3 {
+		t.Fatalf("expected text and 2 image parts, got %d parts", len(latest.Parts))
+	}
+	expectedText := "What went wrong?\n\nAttached file server.log:\n```\npanic: bear in the den\n```"
+	if text := latest.Parts[0].(llms.TextContent).Text; text != expectedText {
+		t.Errorf("expected text %q, got %q", expectedText, text)
+	}
+	if image, ok := latest.Parts[1].(llms.ImageURLContent); !ok || image.URL != "https://example.com/den.png" {
+		t.Errorf("expected image URL part, got %#v", latest.Parts[1])
+	}
+	if image, ok := latest.Parts[2].(llms.BinaryContent); !ok || image.MIMEType != "image/jpeg" || string(image.Data) != "jpeg" {
+		t.Errorf("expected binary image part, got %#v", latest.Parts[2])
+	}
+}
+
+func TestLLMHandler_HandleInteraction(t *testing.T) {
+	tests := []struct {
+		name           string
+		action         string
+		expectedLatest string
+		expectedCount  int
+		expectErr      bool
+	}{
+		// System prompt, history, then the question.
+		{name: "Regenerate", action: RegenerateAction, expectedLatest: "What is a tort?", expectedCount: 3},
+		// System prompt, history, question, answer, then the instruction.
+		{name: "Shorter", action: ShorterAction, expectedLatest: followUpInstructions[ShorterAction], expectedCount: 5},
+		{name: "Explain", action: ExplainAction, expectedLatest: followUpInstructions[ExplainAction], expectedCount: 5},
+		{name: "Unknown action", action: "dance", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := newScripted(t, fakellm.Rule{Reply: "A civil wrong."})
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			h, err := NewLLMHandler(model, nil, nil, logger)
+			if err != nil {
+				t.Fatalf("NewLLMHandler failed: %v", err)
+			}
+
+			component := followUps("What is a tort?", "A wrong.")[0]
+			component.Action = tt.action
+			interaction := &message.Interaction{
+				Request: &message.Request{
+					RequestMessage: message.Message{User: "alice", Content: "Regenerate"},
+					History:        []message.Message{{User: "alice", Content: "Hello", Role: message.UserRole}},
+					Capabilities:   message.Capabilities{Components: true},
+				},
+				Component: component,
+			}
+			response := &message.Response{}
+			err = h.HandleInteraction(context.Background(), interaction, response)
+			if tt.expectErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			sent := model.Calls()[0].Messages
+			if len(sent) != tt.expectedCount {
+				t.Fatalf("expected %d messages, got %d", tt.expectedCount, len(sent))
+			}
+			if latest := sent[len(sent)-1].Parts[0].(llms.TextContent).Text; latest != tt.expectedLatest {
+				t.Errorf("expected latest message %q, got %q", tt.expectedLatest, latest)
+			}
+			if response.ResponseMessage.Content != "A civil wrong." {
+				t.Errorf("unexpected response %q", response.ResponseMessage.Content)
+			}
+			if len(response.Components) != 3 || response.Components[0].State["answer"] != "A civil wrong." {
+				t.Errorf("expected follow-up buttons for the new answer, got %+v", response.Components)
+			}
+		})
+	}
+}
+
+func TestLLMHandler_Handle_StreamingToolCalls(t *testing.T) {
+	// The scripted model streams tool calls as JSON alongside the text, as langchaingo's OpenAI client does.
+	model := newScripted(t,
+		fakellm.Rule{Pattern: "^echo: ", Reply: "It echoed [hi]."},
+		fakellm.Rule{Pattern: "Hello", Reply: "Let me check.", ToolCalls: []fakellm.ToolCall{echoCall}},
+	)
+
+	registry, err := tool.NewRegistry(&echoTool{})
+	if err != nil {
+		t.Fatalf("NewRegistry failed: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	h, err := NewLLMHandler(model, registry, nil, logger)
+	if err != nil {
+		t.Fatalf("NewLLMHandler failed: %v", err)
+	}
+
+	stream := message.NewStream(10)
+	resp := &message.Response{Stream: stream}
+	if err := h.Handle(context.Background(), &message.Request{RequestMessage: message.Message{Content: "Hello"}}, resp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stream.Close()
+
+	var streamed string
+	for chunk := range stream.Chunks() {
+		streamed += chunk
+	}
+	expected := "Let me check.\n\nIt echoed [hi]."
+	if streamed != expected {
+		t.Errorf("expected streamed content %q, got %q", expected, streamed)
+	}
+	if resp.ResponseMessage.Content != expected {
+		t.Errorf("expected content %q, got %q", expected, resp.ResponseMessage.Content)
+	}
+}
+
+func TestIsToolCallChunk(t *testing.T) {
+	tests := []struct {
+		chunk    string
+		expected bool
+	}{
+		{chunk: "Hello", expected: false},
+		{chunk: "[1, 2]", expected: false},
+		{chunk: `{"verdict": "guilty"}`, expected: false},
+		{chunk: `[{"id":"call-1","type":"function","function":{"name":"echo","arguments":""}}]`, expected: true},
+		{chunk: `{"name":"echo","arguments":"{}"}`, expected: true},
+	}
+	for _, tt := range tests {
+		if got := isToolCallChunk([]byte(tt.chunk)); got != tt.expected {
+			t.Errorf("isToolCallChunk(%q) = %v, expected %v", tt.chunk, got, tt.expected)
+		}
+	}
+}
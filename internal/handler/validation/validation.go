@@ -6,7 +6,15 @@ import (
 	"rsandz/bearlawyergo/internal/message"
 )
 
-const MaxMessageLength = 500
+const (
+	MaxMessageLength = 500
+	// MaxAttachments is the most files a message may have attached.
+	MaxAttachments = 4
+	// MaxTextAttachmentSize is the largest text file, in bytes, that may be read into the prompt.
+	MaxTextAttachmentSize = 32 * 1024
+	// MaxImageAttachmentSize is the largest image, in bytes, that may be sent to the model.
+	MaxImageAttachmentSize = 10 * 1024 * 1024
+)
 
 type Handler struct{}
 
@@ -24,6 +32,14 @@ func (h *Handler) Handle(ctx context.Context, msg *message.Request, response *me
 	if !validateMessageExists(msg) {
 		return failValidation(response, "Please provide a message.")
 	}
+	if len(msg.RequestMessage.Attachments) > MaxAttachments {
+		return failValidation(response, fmt.Sprintf("Too many attachments. Please attach at most %d files.", MaxAttachments))
+	}
+	for _, attachment := range msg.RequestMessage.Attachments {
+		if reason, ok := validateAttachmentSize(attachment); !ok {
+			return failValidation(response, reason)
+		}
+	}
 	return nil
 }
 
@@ -38,8 +54,20 @@ func failValidation(response *message.Response, message string) error {
 	return nil
 }
 
+// A message with only attachments exists.
 func validateMessageExists(msg *message.Request) bool {
-	return msg.RequestMessage.Content != ""
+	return msg.RequestMessage.Content != "" || len(msg.RequestMessage.Attachments) > 0
+}
+
+// Returns the reason the attachment is too large, if it is.
+func validateAttachmentSize(attachment message.Attachment) (string, bool) {
+	switch {
+	case attachment.IsImage() && attachment.Size > MaxImageAttachmentSize:
+		return fmt.Sprintf("The image %s is too large. Please keep images under %d MB.", attachment.Name, MaxImageAttachmentSize/(1024*1024)), false
+	case attachment.IsText() && attachment.Size > MaxTextAttachmentSize:
+		return fmt.Sprintf("The file %s is too large. Please keep text files under %d KB.", attachment.Name, MaxTextAttachmentSize/1024), false
+	}
+	return "", true
 }
 
 func validateMessageLength(msg *message.Request) bool {
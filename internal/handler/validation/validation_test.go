@@ -28,6 +28,33 @@ func TestValidationHandler(t *testing.T) {
 			inputRequest:             buildRequestForString(""),
 			expectedPassesValidation: false,
 		},
+		{
+			name:                     "Attachment only",
+			inputRequest:             buildRequestWithAttachments("", message.Attachment{Name: "notes.txt", Size: 100}),
+			expectedPassesValidation: true,
+		},
+		{
+			name:                     "Text attachment too large",
+			inputRequest:             buildRequestWithAttachments("Read this", message.Attachment{Name: "server.log", Size: MaxTextAttachmentSize + 1}),
+			expectedPassesValidation: false,
+		},
+		{
+			name:                     "Image attachment too large",
+			inputRequest:             buildRequestWithAttachments("Look", message.Attachment{Name: "den.png", ContentType: "image/png", Size: MaxImageAttachmentSize + 1}),
+			expectedPassesValidation: false,
+		},
+		{
+			name:                     "Large image allowed",
+			inputRequest:             buildRequestWithAttachments("Look", message.Attachment{Name: "den.png", ContentType: "image/png", Size: MaxTextAttachmentSize + 1}),
+			expectedPassesValidation: true,
+		},
+		{
+			name: "Too many attachments",
+			inputRequest: buildRequestWithAttachments("Look",
+				message.Attachment{Name: "1.txt"}, message.Attachment{Name: "2.txt"}, message.Attachment{Name: "3.txt"},
+				message.Attachment{Name: "4.txt"}, message.Attachment{Name: "5.txt"}),
+			expectedPassesValidation: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -53,3 +80,9 @@ func buildRequestForString(content string) *message.Request {
 		},
 	}
 }
+
+func buildRequestWithAttachments(content string, attachments ...message.Attachment) *message.Request {
+	req := buildRequestForString(content)
+	req.RequestMessage.Attachments = attachments
+	return req
+}
@@ -0,0 +1,279 @@
+package httpapi
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"rsandz/bearlawyergo/internal/adapter/langchain"
+	"rsandz/bearlawyergo/internal/message"
+	"rsandz/bearlawyergo/internal/transport"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// The OpenAI chat completions facade lets OpenAI client libraries talk to the bot as if it were a model.
+// Requests run through the full orchestrator pipeline. The client sends the whole conversation with each request,
+// so conversations are not remembered, and system messages are ignored in favour of the bot's own prompts.
+
+// completionsChannel is the channel ID of chat completion requests.
+const completionsChannel = "openai"
+
+type chatCompletionRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream,omitempty"`
+	// User identifies the end user to the bot.
+	User string `json:"user,omitempty"`
+}
+
+type chatMessage struct {
+	Role string `json:"role"`
+	// Content is either a string or a list of content parts.
+	Content json.RawMessage `json:"content"`
+	Name    string          `json:"name,omitempty"`
+}
+
+type chatContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url,omitempty"`
+}
+
+type chatCompletion struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []chatChoice `json:"choices"`
+	Usage   *chatUsage   `json:"usage,omitempty"`
+}
+
+type chatChoice struct {
+	Index        int        `json:"index"`
+	Message      *chatReply `json:"message,omitempty"`
+	Delta        *chatReply `json:"delta,omitempty"`
+	FinishReason *string    `json:"finish_reason"`
+}
+
+type chatReply struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// chatUsage is always zero: the pipeline does not report token usage.
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type chatError struct {
+	Error chatErrorDetail `json:"error"`
+}
+
+type chatErrorDetail struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+// chatRoles maps OpenAI roles to LangChain message types.
+var chatRoles = map[string]llms.ChatMessageType{
+	"system":    llms.ChatMessageTypeSystem,
+	"developer": llms.ChatMessageTypeSystem,
+	"user":      llms.ChatMessageTypeHuman,
+	"assistant": llms.ChatMessageTypeAI,
+	"tool":      llms.ChatMessageTypeTool,
+	"function":  llms.ChatMessageTypeFunction,
+}
+
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var body chatCompletionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodySize)).Decode(&body); err != nil {
+		writeChatError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	req, err := toCompletionRequest(body)
+	if err != nil {
+		writeChatError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Capabilities.Streaming = body.Stream
+
+	completion := chatCompletion{
+		ID:      newCompletionID(),
+		Created: time.Now().Unix(),
+		Model:   body.Model,
+	}
+	if body.Stream {
+		s.streamChatCompletion(w, r, req, completion)
+		return
+	}
+
+	resp, err := s.dispatcher.DispatchStateless(r.Context(), req, nil)
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "Failed to handle chat completion", "error", err)
+		writeChatError(w, http.StatusInternalServerError, transport.ErrorReply(err))
+		return
+	}
+	stop := "stop"
+	completion.Object = "chat.completion"
+	completion.Choices = []chatChoice{{
+		Message:      &chatReply{Role: "assistant", Content: completionContent(resp)},
+		FinishReason: &stop,
+	}}
+	completion.Usage = &chatUsage{}
+	writeJSON(w, http.StatusOK, completion)
+}
+
+// streamChatCompletion sends the response as chat completion chunks, ending with a [DONE] event.
+func (s *Server) streamChatCompletion(w http.ResponseWriter, r *http.Request, req *message.Request, completion chatCompletion) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher := http.NewResponseController(w)
+
+	completion.Object = "chat.completion.chunk"
+	send := func(delta chatReply, finishReason *string) {
+		chunk := completion
+		chunk.Choices = []chatChoice{{Delta: &delta, FinishReason: finishReason}}
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if err := flusher.Flush(); err != nil {
+			s.logger.WarnContext(r.Context(), "Failed to flush chat completion stream", "error", err)
+		}
+	}
+
+	stream := message.NewStream(streamBuffer)
+	var resp *message.Response
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		resp, err = s.dispatcher.DispatchStateless(r.Context(), req, stream)
+	}()
+
+	send(chatReply{Role: "assistant"}, nil)
+	var streamed strings.Builder
+	for chunk := range stream.Chunks() {
+		streamed.WriteString(chunk)
+		send(chatReply{Content: chunk}, nil)
+	}
+	<-done
+
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "Failed to handle chat completion", "error", err)
+		data, _ := json.Marshal(chatError{Error: chatErrorDetail{Message: transport.ErrorReply(err), Type: "server_error"}})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	} else {
+		// Send whatever was not streamed, such as replies from handlers that do not stream and text outputs.
+		// Handlers only ever stream a prefix of their reply.
+		if rest, ok := strings.CutPrefix(completionContent(resp), streamed.String()); !ok {
+			s.logger.WarnContext(r.Context(), "Streamed chat completion does not match the response")
+		} else if rest != "" {
+			send(chatReply{Content: rest}, nil)
+		}
+		stop := "stop"
+		send(chatReply{}, &stop)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if err := flusher.Flush(); err != nil {
+		s.logger.WarnContext(r.Context(), "Failed to flush chat completion stream", "error", err)
+	}
+}
+
+// toCompletionRequest converts the chat messages to a request for their last message, with the rest as history.
+func toCompletionRequest(body chatCompletionRequest) (*message.Request, error) {
+	contents := make([]llms.MessageContent, 0, len(body.Messages))
+	for _, msg := range body.Messages {
+		role, ok := chatRoles[msg.Role]
+		if !ok {
+			return nil, fmt.Errorf("unknown message role %q", msg.Role)
+		}
+		parts, err := toContentParts(msg.Content)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, llms.MessageContent{Role: role, Parts: parts})
+	}
+	if len(contents) == 0 || contents[len(contents)-1].Role != llms.ChatMessageTypeHuman {
+		return nil, errors.New("the last message must be from the user")
+	}
+
+	messages := langchain.FromLLMMessages(contents)
+	user := body.User
+	if user == "" {
+		user = defaultUser
+	}
+	for i := range messages {
+		if messages[i].Role == message.UserRole {
+			messages[i].User = user
+		} else {
+			messages[i].User = transport.BotName
+		}
+	}
+
+	last := messages[len(messages)-1]
+	last.Timestamp = time.Now()
+	return message.NewRequest(last, messages[:len(messages)-1], completionsChannel), nil
+}
+
+// toContentParts reads message content given as a string or as a list of text and image parts.
+func toContentParts(raw json.RawMessage) ([]llms.ContentPart, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return []llms.ContentPart{llms.TextContent{Text: text}}, nil
+	}
+
+	var parts []chatContentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return nil, errors.New("message content must be a string or a list of content parts")
+	}
+	var result []llms.ContentPart
+	for _, part := range parts {
+		switch {
+		case part.Type == "text":
+			result = append(result, llms.TextContent{Text: part.Text})
+		case part.Type == "image_url" && part.ImageURL != nil:
+			result = append(result, llms.ImageURLContent{URL: part.ImageURL.URL})
+		default:
+			return nil, fmt.Errorf("unsupported content part %q", part.Type)
+		}
+	}
+	return result, nil
+}
+
+// completionContent returns the response message followed by any text outputs. Other outputs cannot be sent to an OpenAI client.
+func completionContent(resp *message.Response) string {
+	content := []string{resp.ResponseMessage.Content}
+	for _, output := range resp.Outputs {
+		if output.Kind == message.TextOutput {
+			content = append(content, output.Text)
+		}
+	}
+	return strings.Join(content, "\n\n")
+}
+
+func newCompletionID() string {
+	id := make([]byte, 12)
+	rand.Read(id)
+	return "chatcmpl-" + hex.EncodeToString(id)
+}
+
+func writeChatError(w http.ResponseWriter, status int, msg string) {
+	errorType := "invalid_request_error"
+	if status >= http.StatusInternalServerError {
+		errorType = "server_error"
+	}
+	writeJSON(w, status, chatError{Error: chatErrorDetail{Message: msg, Type: errorType}})
+}
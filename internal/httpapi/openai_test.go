@@ -0,0 +1,130 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHandleChatCompletions(t *testing.T) {
+	server, dispatcher := newTestServer(t)
+
+	body := `{"model": "bear-lawyer", "messages": [
+		{"role": "system", "content": "You are a pirate"},
+		{"role": "user", "content": "Objection!"},
+		{"role": "assistant", "content": "On what grounds?"},
+		{"role": "user", "content": [{"type": "text", "text": "Hearsay"}]}
+	]}`
+	recorder := post(t, server.routes(), "/v1/chat/completions", body)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", recorder.Code, recorder.Body)
+	}
+	var completion chatCompletion
+	if err := json.NewDecoder(recorder.Body).Decode(&completion); err != nil {
+		t.Fatal(err)
+	}
+	if completion.Object != "chat.completion" || completion.Model != "bear-lawyer" || !strings.HasPrefix(completion.ID, "chatcmpl-") {
+		t.Errorf("completion = %+v", completion)
+	}
+	if len(completion.Choices) != 1 {
+		t.Fatalf("choices = %+v, want one", completion.Choices)
+	}
+	choice := completion.Choices[0]
+	if choice.Message.Role != "assistant" || choice.Message.Content != "Order in the court" {
+		t.Errorf("message = %+v", choice.Message)
+	}
+	if choice.FinishReason == nil || *choice.FinishReason != "stop" {
+		t.Errorf("finish_reason = %v, want stop", choice.FinishReason)
+	}
+
+	// The client keeps the conversation, so nothing is remembered.
+	history, err := dispatcher.History(context.Background(), completionsChannel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 0 {
+		t.Errorf("history = %+v, want chat completions not remembered", history)
+	}
+}
+
+func TestHandleChatCompletionsHistory(t *testing.T) {
+	req, err := toCompletionRequest(chatCompletionRequest{
+		User: "alice",
+		Messages: []chatMessage{
+			{Role: "system", Content: json.RawMessage(`"You are a pirate"`)},
+			{Role: "user", Content: json.RawMessage(`"Objection!"`)},
+			{Role: "assistant", Content: json.RawMessage(`"On what grounds?"`)},
+			{Role: "user", Content: json.RawMessage(`[{"type": "text", "text": "Hearsay"}, {"type": "image_url", "image_url": {"url": "https://example.com/exhibit.png"}}]`)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.RequestMessage.Content != "Hearsay" || req.RequestMessage.User != "alice" || len(req.RequestMessage.Attachments) != 1 {
+		t.Errorf("request message = %+v", req.RequestMessage)
+	}
+	if len(req.History) != 2 || req.History[0].Content != "Objection!" || req.History[1].User != "Bear Lawyer" {
+		t.Errorf("history = %+v", req.History)
+	}
+}
+
+func TestHandleChatCompletionsInvalid(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "malformed json", body: `{"messages":`},
+		{name: "no messages", body: `{"model": "bear-lawyer", "messages": []}`},
+		{name: "last message from assistant", body: `{"messages": [{"role": "assistant", "content": "Hi"}]}`},
+		{name: "unknown role", body: `{"messages": [{"role": "judge", "content": "Order"}]}`},
+		{name: "unsupported part", body: `{"messages": [{"role": "user", "content": [{"type": "input_audio"}]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := post(t, server.routes(), "/v1/chat/completions", tt.body)
+			if recorder.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+			var body chatError
+			if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil || body.Error.Type != "invalid_request_error" {
+				t.Errorf("body = %+v, err = %v", body, err)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletionsStream(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	recorder := post(t, server.routes(), "/v1/chat/completions", `{"model": "bear-lawyer", "stream": true, "messages": [{"role": "user", "content": "Objection!"}]}`)
+	events := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n\n")
+	if got := events[len(events)-1]; got != "data: [DONE]" {
+		t.Fatalf("last event = %q, want [DONE]", got)
+	}
+
+	var content strings.Builder
+	var finishReason string
+	for _, event := range events[:len(events)-1] {
+		var chunk chatCompletion
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(event, "data: ")), &chunk); err != nil {
+			t.Fatalf("event %q: %v", event, err)
+		}
+		if chunk.Object != "chat.completion.chunk" {
+			t.Errorf("object = %q, want chat.completion.chunk", chunk.Object)
+		}
+		content.WriteString(chunk.Choices[0].Delta.Content)
+		if chunk.Choices[0].FinishReason != nil {
+			finishReason = *chunk.Choices[0].FinishReason
+		}
+	}
+	if content.String() != "Order in the court" {
+		t.Errorf("content = %q, want %q", content.String(), "Order in the court")
+	}
+	if finishReason != "stop" {
+		t.Errorf("finish_reason = %q, want stop", finishReason)
+	}
+}
@@ -0,0 +1,271 @@
+// Package httpapi serves the bot over HTTP with a JSON API, for internal tools and testing without Discord.
+package httpapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"rsandz/bearlawyergo/internal/message"
+	"rsandz/bearlawyergo/internal/transport"
+)
+
+const (
+	// defaultUser names the sender of messages that do not give one.
+	defaultUser = "api-user"
+	// maxHistory is how many remembered messages are sent with a request that has no history of its own.
+	maxHistory = 10
+	// maxBodySize is the largest request body, in bytes, the server accepts.
+	maxBodySize = 1 << 20
+	// conversationPrefix keeps the API's conversations apart from those of other transports, which share the
+	// dispatcher's memory, so API clients cannot read or add to a Discord conversation by sending its channel ID.
+	conversationPrefix = "http:"
+
+	streamBuffer    = 32
+	shutdownTimeout = 10 * time.Second
+)
+
+// Server is a transport that answers messages sent over HTTP.
+//
+// POST /v1/messages handles a message and returns the response as JSON.
+// POST /v1/messages/stream does the same, but streams the response as server-sent events:
+// "chunk" events while it is generated, then a "response" event with the complete response,
+// or an "error" event if handling failed.
+// POST /v1/chat/completions answers OpenAI chat completion requests.
+//
+// If a token is set, every request must send it as a bearer token in the Authorization header.
+type Server struct {
+	addr       string
+	dispatcher *transport.Dispatcher
+	server     *http.Server
+	// token is the bearer token clients must send. Empty if the API is open.
+	token  string
+	logger *slog.Logger
+}
+
+// Creates a new server listening on addr, e.g. ":8080".
+func NewServer(addr string, dispatcher *transport.Dispatcher, logger *slog.Logger) *Server {
+	s := &Server{
+		addr:       addr,
+		dispatcher: dispatcher,
+		logger:     logger,
+	}
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           s.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// SetToken sets the bearer token clients must send. An empty token leaves the API open to anyone who can reach it.
+func (s *Server) SetToken(token string) {
+	s.token = token
+}
+
+func (s *Server) Name() string {
+	return "http"
+}
+
+// Start serves requests until ctx is cancelled, then waits for in-flight requests to finish.
+func (s *Server) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+	s.logger.Info("HTTP API listening", "addr", listener.Addr().String())
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := s.server.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("failed to shut down HTTP API: %w", err)
+		}
+		return nil
+	}
+}
+
+// Stop closes any connections left open after Start returns.
+func (s *Server) Stop() error {
+	return s.server.Close()
+}
+
+// Send is not supported: clients of the API only receive responses to their own requests.
+func (s *Server) Send(ctx context.Context, conversationID string, msg message.Message) error {
+	return fmt.Errorf("http transport cannot send unrequested messages: %w", errors.ErrUnsupported)
+}
+
+// Capabilities reports what the API returns. Clients are left to render the outputs.
+// Components are not returned because there is no endpoint to send their interactions back.
+func (s *Server) Capabilities() message.Capabilities {
+	return message.Capabilities{
+		Streaming: true,
+		Reactions: true,
+		Embeds:    true,
+		Files:     true,
+	}
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/messages", s.handleMessage)
+	mux.HandleFunc("POST /v1/messages/stream", s.handleStream)
+	mux.HandleFunc("POST /v1/chat/completions", s.handleChatCompletions)
+	return s.authenticate(mux)
+}
+
+// authenticate rejects requests without the server's bearer token, if it has one.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		s.logger.WarnContext(r.Context(), "Rejected unauthenticated API request", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		const reason = "missing or invalid bearer token"
+		if r.URL.Path == "/v1/chat/completions" {
+			writeChatError(w, http.StatusUnauthorized, reason)
+			return
+		}
+		writeJSON(w, http.StatusUnauthorized, errorBody{Error: reason})
+	})
+}
+
+func (s *Server) handleMessage(w http.ResponseWriter, r *http.Request) {
+	req, err := s.decodeRequest(r, false)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: err.Error()})
+		return
+	}
+
+	resp, err := s.dispatcher.Dispatch(r.Context(), req, nil)
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "Failed to handle API message", "error", err, "channel_id", req.ChannelID)
+		writeJSON(w, http.StatusInternalServerError, errorBody{Error: transport.ErrorReply(err)})
+		return
+	}
+	writeJSON(w, http.StatusOK, toResponseBody(resp))
+}
+
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	req, err := s.decodeRequest(r, true)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher := http.NewResponseController(w)
+
+	stream := message.NewStream(streamBuffer)
+	var resp *message.Response
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		resp, err = s.dispatcher.Dispatch(r.Context(), req, stream)
+	}()
+
+	for chunk := range stream.Chunks() {
+		writeEvent(w, "chunk", chunkBody{Content: chunk})
+		if err := flusher.Flush(); err != nil {
+			s.logger.WarnContext(r.Context(), "Failed to flush API stream", "error", err)
+		}
+	}
+	<-done
+
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "Failed to handle API message", "error", err, "channel_id", req.ChannelID)
+		writeEvent(w, "error", errorBody{Error: transport.ErrorReply(err)})
+	} else {
+		writeEvent(w, "response", toResponseBody(resp))
+	}
+	if err := flusher.Flush(); err != nil {
+		s.logger.WarnContext(r.Context(), "Failed to flush API stream", "error", err)
+	}
+}
+
+// decodeRequest reads the request from the body. Requests without history are sent with the remembered conversation.
+func (s *Server) decodeRequest(r *http.Request, streaming bool) (*message.Request, error) {
+	var body requestBody
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	if body.ChannelID == "" {
+		return nil, errors.New("channel_id is required")
+	}
+
+	msg := body.Message.toMessage()
+	if msg.User == "" {
+		msg.User = defaultUser
+	}
+	msg.Role = message.UserRole
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+
+	req := message.NewRequest(msg, nil, conversationPrefix+body.ChannelID)
+	if body.ThreadID != "" {
+		req.ThreadID = conversationPrefix + body.ThreadID
+	}
+	req.Persona = body.Persona
+	req.Capabilities = s.Capabilities()
+	req.Capabilities.Streaming = streaming
+
+	if body.History != nil {
+		for _, past := range body.History {
+			req.History = append(req.History, past.toMessage())
+		}
+		return req, nil
+	}
+	history, err := s.dispatcher.History(r.Context(), req.ConversationID())
+	if err != nil {
+		s.logger.WarnContext(r.Context(), "Failed to load chat history", "error", err, "conversation_id", req.ConversationID())
+	}
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	req.History = history
+	return req, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeEvent writes a server-sent event with the body as JSON data.
+func writeEvent(w http.ResponseWriter, event string, body any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		data, _ = json.Marshal(errorBody{Error: err.Error()})
+		event = "error"
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
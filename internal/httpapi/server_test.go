@@ -0,0 +1,218 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"rsandz/bearlawyergo/internal/memory"
+	"rsandz/bearlawyergo/internal/message"
+	"rsandz/bearlawyergo/internal/orchestrator"
+	"rsandz/bearlawyergo/internal/transport"
+)
+
+type fakeSummarizer struct{}
+
+func (fakeSummarizer) Summarize(ctx context.Context, previous string, messages []message.Message) (string, error) {
+	return previous, nil
+}
+
+// newTestServer returns a server whose only handler streams "Order in the court" and reports the history it saw.
+func newTestServer(t *testing.T) (*Server, *transport.Dispatcher) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := orchestrator.HandlerFunc(func(ctx context.Context, msg *message.Request, response *message.Response) error {
+		if response.Stream != nil {
+			for _, chunk := range []string{"Order ", "in the court"} {
+				if err := response.Stream.Send(ctx, chunk); err != nil {
+					return err
+				}
+			}
+		}
+		response.ResponseMessage = message.Message{Content: "Order in the court", Role: message.BotRole}
+		response.Annotate("history", strings.Repeat("m", len(msg.History)))
+		response.AddOutput(message.NewReactionOutput("⚖️"))
+		response.ShouldContinueHandling = false
+		return nil
+	})
+	orch := orchestrator.NewOrchestrator(orchestrator.NewRouter(handler), logger)
+	dispatcher := transport.NewDispatcher(orch, memory.NewInMemoryStore(memory.DefaultRetention), fakeSummarizer{}, logger)
+	return NewServer(":0", dispatcher, logger), dispatcher
+}
+
+func post(t *testing.T, handler http.Handler, path string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return recorder
+}
+
+func TestHandleMessage(t *testing.T) {
+	server, dispatcher := newTestServer(t)
+	handler := server.routes()
+
+	recorder := post(t, handler, "/v1/messages", `{"message": {"user": "alice", "content": "Objection!"}, "channel_id": "court"}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", recorder.Code, recorder.Body)
+	}
+	var body responseBody
+	if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Message.Content != "Order in the court" {
+		t.Errorf("content = %q, want %q", body.Message.Content, "Order in the court")
+	}
+	if len(body.Outputs) != 1 || body.Outputs[0].Kind != message.ReactionOutput {
+		t.Errorf("outputs = %+v, want one reaction", body.Outputs)
+	}
+
+	history, err := dispatcher.History(context.Background(), "http:court")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].User != "alice" {
+		t.Fatalf("history = %+v, want the exchange remembered", history)
+	}
+
+	// The next request in the channel is sent with the remembered exchange.
+	recorder = post(t, handler, "/v1/messages", `{"message": {"content": "Sustained?"}, "channel_id": "court"}`)
+	if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(body.Annotations["history"]); got != 2 {
+		t.Errorf("history length = %d, want 2", got)
+	}
+}
+
+func TestHandleMessageOtherTransports(t *testing.T) {
+	server, dispatcher := newTestServer(t)
+
+	// A Discord conversation remembered by the shared dispatcher.
+	discordChannel := "1234567890"
+	req := message.NewRequest(*message.NewMessage("alice", "Objection!", message.UserRole), nil, discordChannel)
+	if _, err := dispatcher.Dispatch(context.Background(), req, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := post(t, server.routes(), "/v1/messages", `{"message": {"content": "What did alice say?"}, "channel_id": "`+discordChannel+`"}`)
+	var body responseBody
+	if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(body.Annotations["history"]); got != 0 {
+		t.Errorf("history length = %d, want the Discord conversation out of reach", got)
+	}
+	history, err := dispatcher.History(context.Background(), discordChannel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Errorf("history = %+v, want the Discord conversation unchanged", history)
+	}
+}
+
+func TestHandleMessageInvalid(t *testing.T) {
+	server, _ := newTestServer(t)
+	handler := server.routes()
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "malformed json", body: `{"message":`},
+		{name: "missing channel", body: `{"message": {"content": "Objection!"}}`},
+		{name: "unknown field", body: `{"message": {"content": "Objection!"}, "channel_id": "court", "judge": "me"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := post(t, handler, "/v1/messages", tt.body)
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+		})
+	}
+
+	t.Run("wrong method", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/messages", nil))
+		if recorder.Code != http.StatusMethodNotAllowed {
+			t.Errorf("status = %d, want %d", recorder.Code, http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func TestHandleStream(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	recorder := post(t, server.routes(), "/v1/messages/stream", `{"message": {"content": "Objection!"}, "channel_id": "court"}`)
+	if got := recorder.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+	want := "event: chunk\ndata: {\"content\":\"Order \"}\n\n" +
+		"event: chunk\ndata: {\"content\":\"in the court\"}\n\n" +
+		"event: response\ndata: "
+	if got := recorder.Body.String(); !strings.HasPrefix(got, want) {
+		t.Errorf("stream = %q, want prefix %q", got, want)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.SetToken("secret")
+	handler := server.routes()
+
+	tests := []struct {
+		name          string
+		path          string
+		body          string
+		authorization string
+		wantStatus    int
+		wantError     string
+	}{
+		{name: "valid token", path: "/v1/messages", body: `{"message": {"content": "Objection!"}, "channel_id": "court"}`, authorization: "Bearer secret", wantStatus: http.StatusOK},
+		{name: "missing token", path: "/v1/messages", authorization: "", wantStatus: http.StatusUnauthorized, wantError: `"error":"missing or invalid bearer token"`},
+		{name: "wrong token", path: "/v1/messages/stream", authorization: "Bearer guess", wantStatus: http.StatusUnauthorized, wantError: `"error":"missing or invalid bearer token"`},
+		{name: "wrong scheme", path: "/v1/messages", authorization: "Basic secret", wantStatus: http.StatusUnauthorized, wantError: `"error":"missing or invalid bearer token"`},
+		{name: "chat completions", path: "/v1/chat/completions", authorization: "", wantStatus: http.StatusUnauthorized, wantError: `"type":"invalid_request_error"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if !strings.Contains(recorder.Body.String(), tt.wantError) {
+				t.Errorf("body = %q, want it to contain %q", recorder.Body.String(), tt.wantError)
+			}
+		})
+	}
+}
+
+func TestAttachmentSize(t *testing.T) {
+	body := messageBody{Attachments: []attachmentBody{
+		{Name: "notes.txt", Data: []byte("Exhibit A"), Size: 1},
+		{Name: "photo.png", URL: "https://example.com/photo.png", ContentType: "image/png", Size: 1},
+	}}
+	attachments := body.toMessage().Attachments
+
+	if attachments[0].Size != len("Exhibit A") {
+		t.Errorf("expected size measured from the data, got %d", attachments[0].Size)
+	}
+	data, err := attachments[0].Load(context.Background())
+	if err != nil || string(data) != "Exhibit A" {
+		t.Errorf("expected attachment data to load, got %q, %v", data, err)
+	}
+	if attachments[1].Size != 0 || attachments[1].Load != nil {
+		t.Errorf("expected size of URL attachment to be unknown, got %+v", attachments[1])
+	}
+}
@@ -0,0 +1,166 @@
+package httpapi
+
+import (
+	"context"
+	"time"
+
+	"rsandz/bearlawyergo/internal/message"
+)
+
+// The JSON bodies of the API. They mirror the message package's types with stable, snake_case field names.
+
+// requestBody is the body of POST /v1/messages.
+type requestBody struct {
+	Message messageBody `json:"message"`
+	// History replaces the remembered conversation if given.
+	History   []messageBody `json:"history,omitempty"`
+	ChannelID string        `json:"channel_id"`
+	ThreadID  string        `json:"thread_id,omitempty"`
+	Persona   string        `json:"persona,omitempty"`
+}
+
+type messageBody struct {
+	User        string           `json:"user,omitempty"`
+	Content     string           `json:"content"`
+	Role        message.Role     `json:"role,omitempty"`
+	Timestamp   time.Time        `json:"timestamp,omitzero"`
+	Attachments []attachmentBody `json:"attachments,omitempty"`
+}
+
+// attachmentBody refers to a file by URL or carries its contents. Only images can be read from a URL.
+type attachmentBody struct {
+	Name        string `json:"name"`
+	URL         string `json:"url,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	// Size is only returned. The size of a request's attachment is measured from its data, never taken from the client.
+	Size int `json:"size,omitempty"`
+	// Data is the file's contents, base64-encoded in JSON. Its size is bounded by the request body limit.
+	Data []byte `json:"data,omitempty"`
+}
+
+type responseBody struct {
+	Message     messageBody       `json:"message"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Outputs     []outputBody      `json:"outputs,omitempty"`
+}
+
+type outputBody struct {
+	Kind      message.OutputKind `json:"kind"`
+	Text      string             `json:"text,omitempty"`
+	Embed     *embedBody         `json:"embed,omitempty"`
+	File      *fileBody          `json:"file,omitempty"`
+	Ephemeral bool               `json:"ephemeral,omitempty"`
+}
+
+type embedBody struct {
+	Title       string           `json:"title,omitempty"`
+	Description string           `json:"description,omitempty"`
+	URL         string           `json:"url,omitempty"`
+	Color       int              `json:"color,omitempty"`
+	Fields      []embedFieldBody `json:"fields,omitempty"`
+	Footer      string           `json:"footer,omitempty"`
+	ImageURL    string           `json:"image_url,omitempty"`
+}
+
+type embedFieldBody struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// fileBody carries the file's data base64 encoded.
+type fileBody struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type,omitempty"`
+	Data        []byte `json:"data"`
+}
+
+// chunkBody is the data of a chunk event on the stream.
+type chunkBody struct {
+	Content string `json:"content"`
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+func (m messageBody) toMessage() message.Message {
+	msg := message.Message{
+		User:      m.User,
+		Content:   m.Content,
+		Role:      m.Role,
+		Timestamp: m.Timestamp,
+	}
+	for _, attachment := range m.Attachments {
+		msg.Attachments = append(msg.Attachments, attachment.toAttachment())
+	}
+	return msg
+}
+
+// toAttachment converts an attachment sent by a client. Attachments sent by URL are never downloaded by the bot,
+// so their size is left unknown.
+func (a attachmentBody) toAttachment() message.Attachment {
+	attachment := message.Attachment{
+		Name:        a.Name,
+		URL:         a.URL,
+		ContentType: a.ContentType,
+	}
+	if a.Data != nil {
+		data := a.Data
+		attachment.Size = len(data)
+		attachment.Load = func(ctx context.Context) ([]byte, error) {
+			return data, nil
+		}
+	}
+	return attachment
+}
+
+func toMessageBody(msg message.Message) messageBody {
+	body := messageBody{
+		User:      msg.User,
+		Content:   msg.Content,
+		Role:      msg.Role,
+		Timestamp: msg.Timestamp,
+	}
+	for _, attachment := range msg.Attachments {
+		body.Attachments = append(body.Attachments, attachmentBody{
+			Name:        attachment.Name,
+			URL:         attachment.URL,
+			ContentType: attachment.ContentType,
+			Size:        attachment.Size,
+		})
+	}
+	return body
+}
+
+func toResponseBody(resp *message.Response) responseBody {
+	body := responseBody{
+		Message:     toMessageBody(resp.ResponseMessage),
+		Annotations: resp.Annotations,
+	}
+	for _, output := range resp.Outputs {
+		body.Outputs = append(body.Outputs, toOutputBody(output))
+	}
+	return body
+}
+
+func toOutputBody(output message.Output) outputBody {
+	body := outputBody{Kind: output.Kind, Text: output.Text, Ephemeral: output.Ephemeral}
+	if embed := output.Embed; embed != nil {
+		body.Embed = &embedBody{
+			Title:       embed.Title,
+			Description: embed.Description,
+			URL:         embed.URL,
+			Color:       embed.Color,
+			Footer:      embed.Footer,
+			ImageURL:    embed.ImageURL,
+		}
+		for _, field := range embed.Fields {
+			body.Embed.Fields = append(body.Embed.Fields, embedFieldBody(field))
+		}
+	}
+	if file := output.File; file != nil {
+		body.File = &fileBody{Name: file.Name, ContentType: file.ContentType, Data: file.Data}
+	}
+	return body
+}
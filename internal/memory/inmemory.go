@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"rsandz/bearlawyergo/internal/message"
+)
+
+type channelHistory struct {
+	entries []Entry
+	summary string
+}
+
+// InMemoryStore is a Store that keeps history in memory. History is lost on restart.
+type InMemoryStore struct {
+	mu        sync.Mutex
+	retention Retention
+	channels  map[string]*channelHistory
+	// seq is the sequence number of the last appended message.
+	seq int64
+}
+
+// NewInMemoryStore creates a new InMemoryStore.
+func NewInMemoryStore(retention Retention) *InMemoryStore {
+	return &InMemoryStore{
+		retention: retention,
+		channels:  make(map[string]*channelHistory),
+	}
+}
+
+func (s *InMemoryStore) Append(ctx context.Context, channel string, msgs ...message.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	ch := s.channel(channel)
+	for _, msg := range stamp(msgs, now) {
+		s.seq++
+		ch.entries = append(ch.entries, Entry{Seq: s.seq, Message: msg})
+	}
+
+	if s.retention.MaxMessages > 0 && len(ch.entries) > s.retention.MaxMessages {
+		ch.entries = slices.Clone(ch.entries[len(ch.entries)-s.retention.MaxMessages:])
+	}
+	if s.retention.MaxAge > 0 {
+		cutoff := now.Add(-s.retention.MaxAge)
+		ch.entries = slices.DeleteFunc(ch.entries, func(entry Entry) bool {
+			return entry.Timestamp.Before(cutoff)
+		})
+	}
+	return nil
+}
+
+func (s *InMemoryStore) Range(ctx context.Context, channel string, query Query) ([]message.Message, error) {
+	entries, err := s.Entries(ctx, channel, query)
+	return messages(entries), err
+}
+
+func (s *InMemoryStore) Entries(ctx context.Context, channel string, query Query) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch, ok := s.channels[channel]
+	if !ok {
+		return nil, nil
+	}
+
+	var entries []Entry
+	for _, entry := range ch.entries {
+		if query.matches(entry.Message) {
+			entries = append(entries, entry)
+		}
+	}
+	if query.Limit > 0 && len(entries) > query.Limit {
+		entries = entries[len(entries)-query.Limit:]
+	}
+	return entries, nil
+}
+
+func (s *InMemoryStore) Summary(ctx context.Context, channel string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch, ok := s.channels[channel]
+	if !ok {
+		return "", nil
+	}
+	return ch.summary, nil
+}
+
+func (s *InMemoryStore) ReplaceWithSummary(ctx context.Context, channel string, through int64, summary string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch := s.channel(channel)
+	ch.entries = slices.DeleteFunc(ch.entries, func(entry Entry) bool {
+		return entry.Seq <= through
+	})
+	ch.summary = summary
+	return nil
+}
+
+func (s *InMemoryStore) Clear(ctx context.Context, channel string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.channels, channel)
+	return nil
+}
+
+func (s *InMemoryStore) Close() error {
+	return nil
+}
+
+// channel returns the channel's history, creating it if needed. The caller must hold s.mu.
+func (s *InMemoryStore) channel(channel string) *channelHistory {
+	ch, ok := s.channels[channel]
+	if !ok {
+		ch = &channelHistory{}
+		s.channels[channel] = ch
+	}
+	return ch
+}
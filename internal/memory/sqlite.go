@@ -0,0 +1,225 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"rsandz/bearlawyergo/internal/message"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS messages (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	channel    TEXT    NOT NULL,
+	user       TEXT    NOT NULL,
+	role       TEXT    NOT NULL,
+	content    TEXT    NOT NULL,
+	roles      TEXT    NOT NULL,
+	created_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS messages_channel_id ON messages (channel, id);
+CREATE TABLE IF NOT EXISTS summaries (
+	channel TEXT PRIMARY KEY,
+	summary TEXT NOT NULL
+);
+`
+
+// SQLiteStore is a Store backed by a SQLite database file, so history survives restarts.
+type SQLiteStore struct {
+	db        *sql.DB
+	retention Retention
+}
+
+// NewSQLiteStore opens or creates the SQLite database at path.
+func NewSQLiteStore(path string, retention Retention) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	// SQLite allows a single writer. Serialize access to avoid SQLITE_BUSY errors.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create history schema: %w", err)
+	}
+	return &SQLiteStore{db: db, retention: retention}, nil
+}
+
+func (s *SQLiteStore) Append(ctx context.Context, channel string, msgs ...message.Message) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now()
+	for _, msg := range stamp(msgs, now) {
+		roles, err := json.Marshal(msg.Roles)
+		if err != nil {
+			return fmt.Errorf("failed to encode roles: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO messages (channel, user, role, content, roles, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			channel, msg.User, string(msg.Role), msg.Content, string(roles), msg.Timestamp.UnixNano(),
+		); err != nil {
+			return fmt.Errorf("failed to insert message: %w", err)
+		}
+	}
+
+	if s.retention.MaxMessages > 0 {
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM messages WHERE channel = ? AND id NOT IN (SELECT id FROM messages WHERE channel = ? ORDER BY id DESC LIMIT ?)`,
+			channel, channel, s.retention.MaxMessages,
+		); err != nil {
+			return fmt.Errorf("failed to apply message retention: %w", err)
+		}
+	}
+	if s.retention.MaxAge > 0 {
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM messages WHERE channel = ? AND created_at < ?`,
+			channel, now.Add(-s.retention.MaxAge).UnixNano(),
+		); err != nil {
+			return fmt.Errorf("failed to apply age retention: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit messages: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Range(ctx context.Context, channel string, query Query) ([]message.Message, error) {
+	entries, err := s.Entries(ctx, channel, query)
+	return messages(entries), err
+}
+
+func (s *SQLiteStore) Entries(ctx context.Context, channel string, query Query) ([]Entry, error) {
+	statement := `SELECT id, user, role, content, roles, created_at FROM messages WHERE channel = ?`
+	args := []any{channel}
+	if !query.Since.IsZero() {
+		statement += ` AND created_at >= ?`
+		args = append(args, query.Since.UnixNano())
+	}
+	if !query.Until.IsZero() {
+		statement += ` AND created_at < ?`
+		args = append(args, query.Until.UnixNano())
+	}
+	// Select newest first so that the limit keeps the most recent messages.
+	statement += ` ORDER BY id DESC`
+	if query.Limit > 0 {
+		statement += ` LIMIT ?`
+		args = append(args, query.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var entry Entry
+		msg := &entry.Message
+		var role, roles string
+		var createdAt int64
+		if err := rows.Scan(&entry.Seq, &msg.User, &role, &msg.Content, &roles, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to read message: %w", err)
+		}
+		if err := json.Unmarshal([]byte(roles), &msg.Roles); err != nil {
+			return nil, fmt.Errorf("failed to decode roles: %w", err)
+		}
+		msg.Role = message.Role(role)
+		msg.Timestamp = time.Unix(0, createdAt)
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read messages: %w", err)
+	}
+
+	slices.Reverse(entries)
+	return entries, nil
+}
+
+func (s *SQLiteStore) Summary(ctx context.Context, channel string) (string, error) {
+	var summary string
+	err := s.db.QueryRowContext(ctx, `SELECT summary FROM summaries WHERE channel = ?`, channel).Scan(&summary)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query summary: %w", err)
+	}
+	return summary, nil
+}
+
+func (s *SQLiteStore) ReplaceWithSummary(ctx context.Context, channel string, through int64, summary string) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM messages WHERE channel = ? AND id <= ?`,
+		channel, through,
+	); err != nil {
+		return fmt.Errorf("failed to delete summarized messages: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO summaries (channel, summary) VALUES (?, ?) ON CONFLICT (channel) DO UPDATE SET summary = excluded.summary`,
+		channel, summary,
+	); err != nil {
+		return fmt.Errorf("failed to store summary: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit summary: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Clear(ctx context.Context, channel string) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM messages WHERE channel = ?`, channel); err != nil {
+		return fmt.Errorf("failed to clear messages: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM summaries WHERE channel = ?`, channel); err != nil {
+		return fmt.Errorf("failed to clear summary: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit clear: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"rsandz/bearlawyergo/internal/message"
+)
+
+// DefaultRetention bounds how much history a store keeps per channel.
+var DefaultRetention = Retention{MaxMessages: 500}
+
+// Query selects messages from a channel's history.
+type Query struct {
+	// Since excludes messages sent before this time. Ignored if zero.
+	Since time.Time
+	// Until excludes messages sent at or after this time. Ignored if zero.
+	Until time.Time
+	// Limit keeps only the most recent matching messages. Ignored if zero or less.
+	Limit int
+}
+
+// Retention limits how much history is kept per channel. Zero values disable a limit.
+type Retention struct {
+	// MaxMessages is the number of most recent messages kept.
+	MaxMessages int
+	// MaxAge is how long messages are kept.
+	MaxAge time.Duration
+}
+
+// Store persists chat history per conversation, keyed by message.Request.ConversationID.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Append adds messages to the end of the channel's history and applies the retention limits.
+	// Messages without a timestamp are stamped with the current time.
+	Append(ctx context.Context, channel string, msgs ...message.Message) error
+	// Range returns the channel's messages matching the query, oldest first.
+	Range(ctx context.Context, channel string, query Query) ([]message.Message, error)
+	// Entries is like Range, but also returns each message's sequence number.
+	Entries(ctx context.Context, channel string, query Query) ([]Entry, error)
+	// Summary returns the summary of the channel's condensed history, or an empty string if there is none.
+	Summary(ctx context.Context, channel string) (string, error)
+	// ReplaceWithSummary removes the channel's messages with sequence numbers up to and including through
+	// and stores the summary in their place. Messages appended since are kept.
+	ReplaceWithSummary(ctx context.Context, channel string, through int64, summary string) error
+	// Clear removes the channel's history and summary.
+	Clear(ctx context.Context, channel string) error
+	// Close releases the store's resources.
+	Close() error
+}
+
+// Entry is a stored message with its sequence number.
+// Sequence numbers increase with every message appended to a store and are never reused.
+type Entry struct {
+	Seq int64
+	message.Message
+}
+
+// messages returns the messages of the entries.
+func messages(entries []Entry) []message.Message {
+	if entries == nil {
+		return nil
+	}
+	msgs := make([]message.Message, len(entries))
+	for i, entry := range entries {
+		msgs[i] = entry.Message
+	}
+	return msgs
+}
+
+func (q Query) matches(msg message.Message) bool {
+	if !q.Since.IsZero() && msg.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !msg.Timestamp.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+func stamp(msgs []message.Message, now time.Time) []message.Message {
+	stamped := make([]message.Message, len(msgs))
+	for i, msg := range msgs {
+		if msg.Timestamp.IsZero() {
+			msg.Timestamp = now
+		}
+		stamped[i] = msg
+	}
+	return stamped
+}
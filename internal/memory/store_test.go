@@ -0,0 +1,164 @@
+package memory
+
+import (
+	"context"
+	"path/filepath"
+	"rsandz/bearlawyergo/internal/message"
+	"slices"
+	"testing"
+	"time"
+)
+
+func storeImplementations(t *testing.T) map[string]func(retention Retention) Store {
+	return map[string]func(retention Retention) Store{
+		"InMemory": func(retention Retention) Store {
+			return NewInMemoryStore(retention)
+		},
+		"SQLite": func(retention Retention) Store {
+			store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "history.db"), retention)
+			if err != nil {
+				t.Fatalf("NewSQLiteStore() unexpected error = %v", err)
+			}
+			t.Cleanup(func() { store.Close() })
+			return store
+		},
+	}
+}
+
+func contents(msgs []message.Message) []string {
+	result := make([]string, len(msgs))
+	for i, msg := range msgs {
+		result[i] = msg.Content
+	}
+	return result
+}
+
+func equalContents(msgs []message.Message, expected ...string) bool {
+	return slices.Equal(contents(msgs), expected)
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for name, newStore := range storeImplementations(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(Retention{})
+
+			msgs := []message.Message{
+				{User: "alice", Role: message.UserRole, Content: "a", Roles: []string{"mod"}, Timestamp: base},
+				{User: "bot", Role: message.BotRole, Content: "b", Timestamp: base.Add(time.Minute)},
+				{User: "alice", Role: message.UserRole, Content: "c", Timestamp: base.Add(2 * time.Minute)},
+			}
+			if err := store.Append(ctx, "channel", msgs...); err != nil {
+				t.Fatalf("Append() unexpected error = %v", err)
+			}
+			if err := store.Append(ctx, "other", message.Message{Content: "x"}); err != nil {
+				t.Fatalf("Append() unexpected error = %v", err)
+			}
+
+			all, err := store.Range(ctx, "channel", Query{})
+			if err != nil {
+				t.Fatalf("Range() unexpected error = %v", err)
+			}
+			if !equalContents(all, "a", "b", "c") {
+				t.Errorf("expected all channel messages in order, got %v", contents(all))
+			}
+			if all[0].User != "alice" || all[0].Role != message.UserRole || len(all[0].Roles) != 1 || !all[0].Timestamp.Equal(base) {
+				t.Errorf("expected message fields to round trip, got %+v", all[0])
+			}
+
+			latest, _ := store.Range(ctx, "channel", Query{Limit: 2})
+			if !equalContents(latest, "b", "c") {
+				t.Errorf("expected latest 2 messages, got %v", contents(latest))
+			}
+
+			window, _ := store.Range(ctx, "channel", Query{Since: base.Add(time.Minute), Until: base.Add(2 * time.Minute)})
+			if !equalContents(window, "b") {
+				t.Errorf("expected messages in time range, got %v", contents(window))
+			}
+
+			entries, err := store.Entries(ctx, "channel", Query{})
+			if err != nil {
+				t.Fatalf("Entries() unexpected error = %v", err)
+			}
+			if len(entries) != 3 || entries[1].Content != "b" || entries[0].Seq >= entries[1].Seq || entries[1].Seq >= entries[2].Seq {
+				t.Fatalf("expected entries in sequence order, got %+v", entries)
+			}
+			if err := store.ReplaceWithSummary(ctx, "channel", entries[1].Seq, "summary"); err != nil {
+				t.Fatalf("ReplaceWithSummary() unexpected error = %v", err)
+			}
+			if summary, _ := store.Summary(ctx, "channel"); summary != "summary" {
+				t.Errorf("expected summary, got %q", summary)
+			}
+			remaining, _ := store.Range(ctx, "channel", Query{})
+			if !equalContents(remaining, "c") {
+				t.Errorf("expected summarized messages to be removed, got %v", contents(remaining))
+			}
+
+			if err := store.Clear(ctx, "channel"); err != nil {
+				t.Fatalf("Clear() unexpected error = %v", err)
+			}
+			cleared, _ := store.Range(ctx, "channel", Query{})
+			summary, _ := store.Summary(ctx, "channel")
+			if len(cleared) != 0 || summary != "" {
+				t.Error("expected empty channel after clear")
+			}
+			if other, _ := store.Range(ctx, "other", Query{}); !equalContents(other, "x") {
+				t.Errorf("expected other channels to be unaffected, got %v", contents(other))
+			}
+		})
+	}
+}
+
+func TestStore_Retention(t *testing.T) {
+	ctx := context.Background()
+
+	for name, newStore := range storeImplementations(t) {
+		t.Run(name+"/MaxMessages", func(t *testing.T) {
+			store := newStore(Retention{MaxMessages: 2})
+			for _, content := range []string{"a", "b", "c"} {
+				if err := store.Append(ctx, "channel", message.Message{Content: content}); err != nil {
+					t.Fatalf("Append() unexpected error = %v", err)
+				}
+			}
+			if msgs, _ := store.Range(ctx, "channel", Query{}); !equalContents(msgs, "b", "c") {
+				t.Errorf("expected oldest messages to be dropped, got %v", contents(msgs))
+			}
+		})
+
+		t.Run(name+"/MaxAge", func(t *testing.T) {
+			store := newStore(Retention{MaxAge: time.Hour})
+			old := message.Message{Content: "old", Timestamp: time.Now().Add(-2 * time.Hour)}
+			if err := store.Append(ctx, "channel", old, message.Message{Content: "new"}); err != nil {
+				t.Fatalf("Append() unexpected error = %v", err)
+			}
+			if msgs, _ := store.Range(ctx, "channel", Query{}); !equalContents(msgs, "new") {
+				t.Errorf("expected expired messages to be dropped, got %v", contents(msgs))
+			}
+		})
+	}
+}
+
+func TestSQLiteStore_Persists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "history.db")
+
+	store, err := NewSQLiteStore(path, Retention{})
+	if err != nil {
+		t.Fatalf("NewSQLiteStore() unexpected error = %v", err)
+	}
+	if err := store.Append(ctx, "channel", message.Message{Content: "remember me"}); err != nil {
+		t.Fatalf("Append() unexpected error = %v", err)
+	}
+	store.Close()
+
+	reopened, err := NewSQLiteStore(path, Retention{})
+	if err != nil {
+		t.Fatalf("NewSQLiteStore() unexpected error = %v", err)
+	}
+	defer reopened.Close()
+	if msgs, _ := reopened.Range(ctx, "channel", Query{}); !equalContents(msgs, "remember me") {
+		t.Errorf("expected history to survive reopening, got %v", contents(msgs))
+	}
+}
@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"rsandz/bearlawyergo/internal/message"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+const (
+	// DefaultSummaryThreshold is the number of messages that triggers summarization.
+	DefaultSummaryThreshold = 20
+	// DefaultSummaryKeep is the number of recent messages kept verbatim after summarization.
+	DefaultSummaryKeep = 10
+)
+
+// Summarizer condenses conversation turns into a running summary.
+type Summarizer interface {
+	// Summarize folds the messages into the previous summary and returns the new summary.
+	Summarize(ctx context.Context, previous string, messages []message.Message) (string, error)
+}
+
+// LLMSummarizer asks a language model to write the summary.
+type LLMSummarizer struct {
+	llm    llms.Model
+	prompt string
+}
+
+// NewLLMSummarizer creates a new LLMSummarizer that instructs the model with the given prompt.
+func NewLLMSummarizer(llm llms.Model, prompt string) *LLMSummarizer {
+	return &LLMSummarizer{llm: llm, prompt: prompt}
+}
+
+func (s *LLMSummarizer) Summarize(ctx context.Context, previous string, messages []message.Message) (string, error) {
+	var transcript strings.Builder
+	if previous != "" {
+		fmt.Fprintf(&transcript, "Summary so far:\n%s\n\n", previous)
+	}
+	transcript.WriteString("New messages:\n")
+	for _, msg := range messages {
+		speaker := msg.User
+		if speaker == "" {
+			speaker = string(msg.Role)
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n", speaker, msg.Content)
+	}
+
+	resp, err := s.llm.GenerateContent(ctx, []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, s.prompt),
+		llms.TextParts(llms.ChatMessageTypeHuman, transcript.String()),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize conversation: %w", err)
+	}
+	if len(resp.Choices) < 1 {
+		return "", errors.New("empty response from model")
+	}
+	return strings.TrimSpace(resp.Choices[0].Content), nil
+}
+
+// Compactor condenses older messages in a Store into the channel's running summary.
+type Compactor struct {
+	// locks serialize compaction of each channel so the same messages are not summarized twice.
+	// Different channels compact concurrently.
+	locks      keyedMutex
+	store      Store
+	summarizer Summarizer
+	threshold  int
+	keep       int
+}
+
+// NewCompactor creates a new Compactor.
+// Once a channel holds more than threshold messages, Compact summarizes all but the latest keep messages.
+func NewCompactor(store Store, summarizer Summarizer, threshold int, keep int) *Compactor {
+	return &Compactor{
+		store:      store,
+		summarizer: summarizer,
+		threshold:  threshold,
+		keep:       min(keep, threshold),
+	}
+}
+
+// Compact summarizes the channel's older messages once the threshold is exceeded.
+// On failure the history is left unchanged so that no messages are lost.
+// Only the summarized messages are removed, so messages appended while summarizing are kept.
+func (c *Compactor) Compact(ctx context.Context, channel string) error {
+	unlock := c.locks.lock(channel)
+	defer unlock()
+
+	entries, err := c.store.Entries(ctx, channel, Query{})
+	if err != nil {
+		return err
+	}
+	if len(entries) <= c.threshold {
+		return nil
+	}
+
+	previous, err := c.store.Summary(ctx, channel)
+	if err != nil {
+		return err
+	}
+
+	summarized := entries[:len(entries)-c.keep]
+	summary, err := c.summarizer.Summarize(ctx, previous, messages(summarized))
+	if err != nil {
+		return err
+	}
+	return c.store.ReplaceWithSummary(ctx, channel, summarized[len(summarized)-1].Seq, summary)
+}
+
+// keyedMutex is a set of mutexes, one per key, that are freed once nobody holds or waits for them.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	// refs counts the goroutines holding or waiting for the lock.
+	refs int
+}
+
+// lock locks the key's mutex and returns the function that unlocks it.
+func (m *keyedMutex) lock(key string) func() {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = make(map[string]*keyedLock)
+	}
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		l.refs--
+		if l.refs == 0 {
+			delete(m.locks, key)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"rsandz/bearlawyergo/internal/message"
+	"strings"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+type mockSummarizer struct {
+	calls int
+	err   error
+	// during is called while summarizing, if set.
+	during func()
+}
+
+func (s *mockSummarizer) Summarize(ctx context.Context, previous string, messages []message.Message) (string, error) {
+	s.calls++
+	if s.during != nil {
+		s.during()
+	}
+	if s.err != nil {
+		return "", s.err
+	}
+	var contents []string
+	for _, msg := range messages {
+		contents = append(contents, msg.Content)
+	}
+	return strings.TrimSpace(previous + " " + strings.Join(contents, " ")), nil
+}
+
+func TestCompactor_Compact(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryStore(Retention{})
+	summarizer := &mockSummarizer{}
+	compactor := NewCompactor(store, summarizer, 4, 2)
+
+	appendContents := func(from, to int) {
+		for i := from; i < to; i++ {
+			if err := store.Append(ctx, "channel", message.Message{Content: fmt.Sprint(i)}); err != nil {
+				t.Fatalf("Append() unexpected error = %v", err)
+			}
+		}
+	}
+
+	appendContents(0, 4)
+	if err := compactor.Compact(ctx, "channel"); err != nil {
+		t.Fatalf("Compact() unexpected error = %v", err)
+	}
+	if summarizer.calls != 0 {
+		t.Error("expected no summarization below the threshold")
+	}
+
+	appendContents(4, 5)
+	if err := compactor.Compact(ctx, "channel"); err != nil {
+		t.Fatalf("Compact() unexpected error = %v", err)
+	}
+	if summary, _ := store.Summary(ctx, "channel"); summary != "0 1 2" {
+		t.Errorf("expected summary of older messages, got %q", summary)
+	}
+	if msgs, _ := store.Range(ctx, "channel", Query{}); len(msgs) != 2 || msgs[0].Content != "3" {
+		t.Errorf("expected latest 2 messages to be kept, got %v", msgs)
+	}
+
+	appendContents(5, 8)
+	if err := compactor.Compact(ctx, "channel"); err != nil {
+		t.Fatalf("Compact() unexpected error = %v", err)
+	}
+	if summary, _ := store.Summary(ctx, "channel"); summary != "0 1 2 3 4 5" {
+		t.Errorf("expected running summary to include previous summary, got %q", summary)
+	}
+}
+
+func TestCompactor_Compact_Error(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryStore(Retention{})
+	compactor := NewCompactor(store, &mockSummarizer{err: errors.New("llm error")}, 1, 0)
+	if err := store.Append(ctx, "channel", message.Message{Content: "a"}, message.Message{Content: "b"}); err != nil {
+		t.Fatalf("Append() unexpected error = %v", err)
+	}
+
+	if err := compactor.Compact(ctx, "channel"); err == nil {
+		t.Fatal("expected error but got none")
+	}
+	if msgs, _ := store.Range(ctx, "channel", Query{}); len(msgs) != 2 {
+		t.Error("expected messages to be kept when summarization fails")
+	}
+}
+
+func TestCompactor_Compact_ConcurrentChanges(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryStore(Retention{MaxMessages: 5})
+	summarizer := &mockSummarizer{}
+	compactor := NewCompactor(store, summarizer, 4, 1)
+	for i := range 5 {
+		if err := store.Append(ctx, "channel", message.Message{Content: fmt.Sprint(i)}); err != nil {
+			t.Fatalf("Append() unexpected error = %v", err)
+		}
+	}
+
+	// New messages arrive while summarizing, and retention prunes the oldest ones.
+	summarizer.during = func() {
+		if err := store.Append(ctx, "channel", message.Message{Content: "5"}, message.Message{Content: "6"}); err != nil {
+			t.Fatalf("Append() unexpected error = %v", err)
+		}
+	}
+	if err := compactor.Compact(ctx, "channel"); err != nil {
+		t.Fatalf("Compact() unexpected error = %v", err)
+	}
+
+	if summary, _ := store.Summary(ctx, "channel"); summary != "0 1 2 3" {
+		t.Errorf("expected summary of older messages, got %q", summary)
+	}
+	msgs, _ := store.Range(ctx, "channel", Query{})
+	var got []string
+	for _, msg := range msgs {
+		got = append(got, msg.Content)
+	}
+	if strings.Join(got, " ") != "4 5 6" {
+		t.Errorf("expected only summarized messages to be removed, got %v", got)
+	}
+}
+
+// blockingSummarizer blocks summarizing the channel whose messages start with "slow" until released.
+type blockingSummarizer struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingSummarizer) Summarize(ctx context.Context, previous string, messages []message.Message) (string, error) {
+	if messages[0].Content == "slow" {
+		close(s.started)
+		<-s.release
+	}
+	return messages[0].Content, nil
+}
+
+func TestCompactor_Compact_PerChannel(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryStore(Retention{})
+	for _, channel := range []string{"slow", "fast"} {
+		if err := store.Append(ctx, channel, message.Message{Content: channel}, message.Message{Content: "b"}); err != nil {
+			t.Fatalf("Append() unexpected error = %v", err)
+		}
+	}
+	summarizer := &blockingSummarizer{started: make(chan struct{}), release: make(chan struct{})}
+	compactor := NewCompactor(store, summarizer, 1, 0)
+
+	done := make(chan error)
+	go func() { done <- compactor.Compact(ctx, "slow") }()
+	<-summarizer.started
+
+	if err := compactor.Compact(ctx, "fast"); err != nil {
+		t.Fatalf("Compact() unexpected error = %v", err)
+	}
+	if summary, _ := store.Summary(ctx, "fast"); summary != "fast" {
+		t.Errorf("expected other channel to be summarized while one is busy, got %q", summary)
+	}
+
+	close(summarizer.release)
+	if err := <-done; err != nil {
+		t.Fatalf("Compact() unexpected error = %v", err)
+	}
+}
+
+type mockLLM struct {
+	messages []llms.MessageContent
+}
+
+func (m *mockLLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	m.messages = messages
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: " new summary \n"}}}, nil
+}
+
+func (m *mockLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return "", nil
+}
+
+func TestLLMSummarizer(t *testing.T) {
+	llm := &mockLLM{}
+	summarizer := NewLLMSummarizer(llm, "summarize")
+
+	summary, err := summarizer.Summarize(context.Background(), "old summary", []message.Message{
+		{User: "alice", Role: message.UserRole, Content: "hello"},
+		{Role: message.BotRole, Content: "greetings"},
+	})
+	if err != nil {
+		t.Fatalf("Summarize() unexpected error = %v", err)
+	}
+	if summary != "new summary" {
+		t.Errorf("expected trimmed summary, got %q", summary)
+	}
+
+	transcript := llm.messages[1].Parts[0].(llms.TextContent).Text
+	for _, want := range []string{"old summary", "alice: hello", "bot: greetings"} {
+		if !strings.Contains(transcript, want) {
+			t.Errorf("expected transcript to contain %q, got %q", want, transcript)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package message
+
+import (
+	"context"
+	"path"
+	"slices"
+	"strings"
+)
+
+// textExtensions are file extensions treated as text regardless of the reported content type.
+var textExtensions = []string{".txt", ".md", ".go", ".log", ".json", ".yaml", ".yml", ".csv"}
+
+// Represents a file attached to a message.
+type Attachment struct {
+	// Name is the file name, including its extension.
+	Name string
+	// URL is where the file can be downloaded from. Empty if the file is only available through Load.
+	URL string
+	// ContentType is the MIME type reported for the file. May be empty if unknown.
+	ContentType string
+	// Size of the file in bytes.
+	Size int
+	// Load fetches the contents of the file. Nil if the contents cannot be loaded.
+	Load func(ctx context.Context) ([]byte, error)
+}
+
+// IsImage reports whether the attachment is an image.
+func (a Attachment) IsImage() bool {
+	return strings.HasPrefix(a.ContentType, "image/")
+}
+
+// IsText reports whether the attachment is a text file that can be read as part of the message.
+func (a Attachment) IsText() bool {
+	if strings.HasPrefix(a.ContentType, "text/") {
+		return true
+	}
+	return slices.Contains(textExtensions, strings.ToLower(path.Ext(a.Name)))
+}
@@ -0,0 +1,15 @@
+package message
+
+// Capabilities describes what a transport can render.
+// Handlers may use it to avoid producing output the user will never see.
+type Capabilities struct {
+	// Streaming shows the response message as it is generated.
+	Streaming bool
+	Reactions bool
+	Embeds    bool
+	Files     bool
+	// Components renders buttons and select menus and sends their interactions back.
+	Components bool
+	// Ephemeral shows outputs only to the requester.
+	Ephemeral bool
+}
@@ -0,0 +1,71 @@
+package message
+
+type ComponentKind string
+
+const (
+	ButtonComponent ComponentKind = "button"
+	SelectComponent ComponentKind = "select"
+)
+
+// Component is an interactive control attached to the response message, such as a button or a select menu.
+// Using a component sends an Interaction back to the handler that added it.
+type Component struct {
+	Kind ComponentKind
+	// Label is the text of a button or the placeholder of a select menu.
+	Label string
+	// Options are the choices of a select menu.
+	Options []SelectOption
+	// Modal is a form shown when the button is pressed. Its fields are sent with the interaction.
+	// Nil sends the interaction as soon as the button is pressed.
+	Modal *Modal
+	// Action tells the handler what the component does, e.g. "regenerate".
+	Action string
+	// State is returned to the handler with the interaction, so that it can continue where it left off.
+	State map[string]string
+	// Route names the route of the handler that added the component. Set by the orchestrator.
+	Route string
+}
+
+type SelectOption struct {
+	Label string
+	Value string
+}
+
+// Modal is a form with text fields.
+type Modal struct {
+	Title  string
+	Fields []ModalField
+}
+
+type ModalField struct {
+	// Name identifies the field's value in Interaction.Fields.
+	Name        string
+	Label       string
+	Placeholder string
+	// Long allows the value to span multiple lines.
+	Long     bool
+	Required bool
+}
+
+// Interaction is a user's use of a component.
+type Interaction struct {
+	// Request describes who used the component and where, with the conversation so far.
+	// Its request message holds the component's label.
+	Request *Request
+	// Component is the component that was used.
+	Component Component
+	// Values are the options chosen in a select menu.
+	Values []string
+	// Fields are the values entered in the button's modal, keyed by field name.
+	Fields map[string]string
+}
+
+// Creates a button.
+func NewButton(label string, action string, state map[string]string) Component {
+	return Component{Kind: ButtonComponent, Label: label, Action: action, State: state}
+}
+
+// Creates a select menu.
+func NewSelect(placeholder string, action string, state map[string]string, options ...SelectOption) Component {
+	return Component{Kind: SelectComponent, Label: placeholder, Action: action, State: state, Options: options}
+}
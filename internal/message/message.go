@@ -1,5 +1,10 @@
 package message
 
+import (
+	"slices"
+	"time"
+)
+
 type Role string
 
 const (
@@ -11,19 +16,49 @@ const (
 type Request struct {
 	// RequestMessage is the message that started this request.
 	RequestMessage Message
+	// RawContent is the request message as the transport received it, before markup such as mentions was
+	// converted to plain text. Handlers that need the IDs in the markup can read them from here.
+	// Empty if the transport does no conversion.
+	RawContent string
 	// History contains prior messages in this conversation context.
 	History []Message
-	// Channel identifies the medium or location of the request.
-	Channel string
+	// GuildID identifies the server the request was sent in. Empty outside of Discord servers.
+	GuildID string
+	// ChannelID identifies the medium or location of the request, e.g. a Discord channel or the CLI.
+	// For requests sent in a thread, this is the thread's ID.
+	ChannelID string
+	// ThreadID identifies the thread the request was sent in. Empty outside of threads.
+	ThreadID string
+	// ParentID identifies the channel the thread belongs to. Empty outside of threads.
+	ParentID string
+	// DirectMessage reports whether the request was sent privately to the bot rather than in a shared channel.
+	DirectMessage bool
+	// Edited reports whether the request message is an edit of a message that was already answered.
+	Edited bool
+	// Capabilities describes what the transport the request came from can render.
+	Capabilities Capabilities
+	// Summary condenses the conversation that came before History. Empty if there is none.
+	Summary string
+	// Persona names the personality the bot should answer with. Empty for the default persona.
+	Persona string
 }
 
 // Creates a new request.
-func NewRequest(requestMessage Message, history []Message, channel string) *Request {
+func NewRequest(requestMessage Message, history []Message, channelID string) *Request {
 	return &Request{
 		RequestMessage: requestMessage,
 		History:        history,
-		Channel:        channel,
+		ChannelID:      channelID,
+	}
+}
+
+// ConversationID identifies the conversation the request belongs to: the thread if there is one, otherwise the channel.
+// Conversation history is keyed by this ID.
+func (r *Request) ConversationID() string {
+	if r.ThreadID != "" {
+		return r.ThreadID
 	}
+	return r.ChannelID
 }
 
 // Represents a response to a message.
@@ -32,6 +67,14 @@ type Response struct {
 	ResponseMessage Message
 	// ShouldContinueHandling indicates whether the request requires further processing by other handlers.
 	ShouldContinueHandling bool
+	// Annotations carry metadata about the request, such as moderation verdicts, keyed by name.
+	Annotations map[string]string
+	// Outputs are sent after the response message, in order.
+	Outputs []Output
+	// Components are attached to the response message.
+	Components []Component
+	// Stream receives chunks of the response message as they are generated. Nil if the caller does not stream.
+	Stream *Stream
 }
 
 // Creates a new response.
@@ -42,6 +85,60 @@ func NewResponse(responseMessage Message, shouldContinueHandling bool) *Response
 	}
 }
 
+// Annotate sets an annotation on the response.
+func (r *Response) Annotate(key string, value string) {
+	if r.Annotations == nil {
+		r.Annotations = make(map[string]string)
+	}
+	r.Annotations[key] = value
+}
+
+// AddOutput appends outputs to the response.
+func (r *Response) AddOutput(outputs ...Output) {
+	r.Outputs = append(r.Outputs, outputs...)
+}
+
+// AddComponent attaches components to the response message.
+func (r *Response) AddComponent(components ...Component) {
+	r.Components = append(r.Components, components...)
+}
+
+// Reactions returns the emoji of the response's reaction outputs, in order.
+func (r *Response) Reactions() []string {
+	var reactions []string
+	for _, output := range r.Outputs {
+		if output.Kind == ReactionOutput {
+			reactions = append(reactions, output.Text)
+		}
+	}
+	return reactions
+}
+
+// Merge folds the contributions of another response into this one.
+// Annotations from other override existing keys, outputs are appended unless they repeat a reaction,
+// components are appended, and the response message is replaced if other set one.
+// Handling stops if either response asks to stop.
+func (r *Response) Merge(other *Response) {
+	for key, value := range other.Annotations {
+		r.Annotate(key, value)
+	}
+	reactions := r.Reactions()
+	for _, output := range other.Outputs {
+		if output.Kind == ReactionOutput {
+			if slices.Contains(reactions, output.Text) {
+				continue
+			}
+			reactions = append(reactions, output.Text)
+		}
+		r.AddOutput(output)
+	}
+	r.AddComponent(other.Components...)
+	if other.ResponseMessage.Content != "" {
+		r.ResponseMessage = other.ResponseMessage
+	}
+	r.ShouldContinueHandling = r.ShouldContinueHandling && other.ShouldContinueHandling
+}
+
 // Represents a chat message.
 type Message struct {
 	// User who sent this message
@@ -50,6 +147,12 @@ type Message struct {
 	Content string
 	// Role of the user
 	Role Role
+	// Roles held by the user in the medium, e.g. Discord role IDs
+	Roles []string
+	// Timestamp is when the message was sent. Zero if unknown.
+	Timestamp time.Time
+	// Attachments are files sent with the message.
+	Attachments []Attachment
 }
 
 // Creates a new message.
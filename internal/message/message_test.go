@@ -0,0 +1,27 @@
+package message
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestResponse_Merge(t *testing.T) {
+	resp := &Response{ShouldContinueHandling: true}
+	resp.AddOutput(NewReactionOutput("🐻"))
+
+	other := &Response{ShouldContinueHandling: true, ResponseMessage: Message{Content: "Order is restored."}}
+	other.Annotate("verdict", "ok")
+	other.AddOutput(NewReactionOutput("🐻"), NewReactionOutput("⚖️"), NewTextOutput("Case closed."))
+	resp.Merge(other)
+
+	expected := []Output{NewReactionOutput("🐻"), NewReactionOutput("⚖️"), NewTextOutput("Case closed.")}
+	if !slices.Equal(resp.Outputs, expected) {
+		t.Errorf("expected outputs %v, got %v", expected, resp.Outputs)
+	}
+	if !slices.Equal(resp.Reactions(), []string{"🐻", "⚖️"}) {
+		t.Errorf("unexpected reactions %v", resp.Reactions())
+	}
+	if resp.ResponseMessage.Content != "Order is restored." || resp.Annotations["verdict"] != "ok" {
+		t.Errorf("expected response message and annotations to be merged, got %+v", resp)
+	}
+}
@@ -0,0 +1,71 @@
+package message
+
+type OutputKind string
+
+const (
+	TextOutput     OutputKind = "text"
+	EmbedOutput    OutputKind = "embed"
+	FileOutput     OutputKind = "file"
+	ReactionOutput OutputKind = "reaction"
+)
+
+// Output is something sent to the user in addition to the response message.
+// Transports render the outputs they support and fall back to plain text for the rest.
+type Output struct {
+	Kind OutputKind
+	// Text is the content of a text output, the caption of a file, or the emoji of a reaction.
+	Text string
+	// Embed is the card of an embed output.
+	Embed *Embed
+	// File is the file of a file output.
+	File *File
+	// Reply sends the output as a reply to the request message.
+	Reply bool
+	// Ephemeral shows the output only to the user who sent the request.
+	Ephemeral bool
+}
+
+// Embed is a formatted card with a title, description and fields.
+type Embed struct {
+	Title       string
+	Description string
+	URL         string
+	// Color is an RGB colour such as 0x8B4513. Zero uses the transport's default.
+	Color    int
+	Fields   []EmbedField
+	Footer   string
+	ImageURL string
+}
+
+type EmbedField struct {
+	Name   string
+	Value  string
+	Inline bool
+}
+
+// File is a file generated by a handler.
+type File struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Creates a text output.
+func NewTextOutput(text string) Output {
+	return Output{Kind: TextOutput, Text: text}
+}
+
+// Creates an embed output.
+func NewEmbedOutput(embed Embed) Output {
+	return Output{Kind: EmbedOutput, Embed: &embed}
+}
+
+// Creates a file output with an optional caption.
+func NewFileOutput(file File, caption string) Output {
+	return Output{Kind: FileOutput, File: &file, Text: caption}
+}
+
+// Creates an output that reacts to the request message with the emoji.
+func NewReactionOutput(emoji string) Output {
+	return Output{Kind: ReactionOutput, Text: emoji}
+}
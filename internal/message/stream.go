@@ -0,0 +1,64 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrStreamClosed is returned when sending to a stream that has been closed.
+var ErrStreamClosed = errors.New("stream closed")
+
+// Stream carries chunks of a response message as they are generated.
+// A stream is written to by handlers and read by the transport that created it.
+type Stream struct {
+	mu     sync.RWMutex
+	closed bool
+	chunks chan string
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Creates a new stream with the given chunk buffer size.
+func NewStream(buffer int) *Stream {
+	return &Stream{
+		chunks: make(chan string, buffer),
+		done:   make(chan struct{}),
+	}
+}
+
+// Send delivers a chunk to the reader, blocking until it is accepted, the context is done or the stream is closed.
+func (s *Stream) Send(ctx context.Context, chunk string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrStreamClosed
+	}
+
+	select {
+	case s.chunks <- chunk:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.done:
+		return ErrStreamClosed
+	}
+}
+
+// Chunks returns the channel chunks are delivered on. It is closed once the stream is closed.
+func (s *Stream) Chunks() <-chan string {
+	return s.chunks
+}
+
+// Close closes the stream. Pending and later sends fail with ErrStreamClosed.
+// It is safe to call Close more than once.
+func (s *Stream) Close() {
+	s.once.Do(func() {
+		// Unblock in-flight sends before taking the write lock they hold.
+		close(s.done)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.closed = true
+		close(s.chunks)
+	})
+}
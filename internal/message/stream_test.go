@@ -0,0 +1,54 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	stream := NewStream(0)
+
+	go func() {
+		for _, chunk := range []string{"a", "b", "c"} {
+			if err := stream.Send(context.Background(), chunk); err != nil {
+				t.Errorf("Send() unexpected error = %v", err)
+			}
+		}
+		stream.Close()
+	}()
+
+	var got string
+	for chunk := range stream.Chunks() {
+		got += chunk
+	}
+	if got != "abc" {
+		t.Errorf("expected %q, got %q", "abc", got)
+	}
+
+	if err := stream.Send(context.Background(), "d"); !errors.Is(err, ErrStreamClosed) {
+		t.Errorf("expected ErrStreamClosed after close, got %v", err)
+	}
+	stream.Close()
+}
+
+func TestStream_CloseUnblocksSend(t *testing.T) {
+	stream := NewStream(0)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- stream.Send(context.Background(), "never read")
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	stream.Close()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrStreamClosed) {
+			t.Errorf("expected ErrStreamClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Send did not unblock after Close")
+	}
+}
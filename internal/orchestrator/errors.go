@@ -0,0 +1,39 @@
+package orchestrator
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrHandlerTimeout is returned when a handler does not finish before its deadline.
+	ErrHandlerTimeout = errors.New("handler timed out")
+	// ErrHandlerPanicked is returned when a handler panics while handling a message.
+	ErrHandlerPanicked = errors.New("handler panicked")
+	// ErrNoInteractionHandler is returned when the handler that added a component cannot handle its interactions.
+	ErrNoInteractionHandler = errors.New("no handler for interaction")
+)
+
+// HandlerError describes a failure of a single handler invocation.
+// Use errors.Is with ErrHandlerTimeout or ErrHandlerPanicked to tell failures apart.
+type HandlerError struct {
+	// HandlerType is the Go type of the handler that failed.
+	HandlerType string
+	// Err is the underlying failure.
+	Err error
+	// PanicValue is the value recovered from a panic, if any.
+	PanicValue any
+	// Stack is the goroutine stack captured when the handler panicked.
+	Stack []byte
+}
+
+func (e *HandlerError) Error() string {
+	if e.PanicValue != nil {
+		return fmt.Sprintf("%s: %v: %v", e.HandlerType, e.Err, e.PanicValue)
+	}
+	return fmt.Sprintf("%s: %v", e.HandlerType, e.Err)
+}
+
+func (e *HandlerError) Unwrap() error {
+	return e.Err
+}
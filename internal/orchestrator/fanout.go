@@ -0,0 +1,48 @@
+package orchestrator
+
+import (
+	"context"
+	"rsandz/bearlawyergo/internal/message"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// ParallelHandler can be implemented by a handler that does not depend on other handlers,
+// such as moderation checks or logging sinks.
+// Adjacent parallel handlers run concurrently and their contributions are merged into the response.
+type ParallelHandler interface {
+	Parallel() bool
+}
+
+func isParallel(h Handler) bool {
+	ph, ok := h.(ParallelHandler)
+	return ok && ph.Parallel()
+}
+
+// runParallel runs the routes concurrently. The first error cancels the remaining routes.
+// Each route writes to its own response, which is merged into the shared response in route order
+// so the result does not depend on scheduling.
+func (orchestrator *Orchestrator) runParallel(ctx context.Context, routes []Route, msg *message.Request, response *message.Response) error {
+	contributions := make([]*message.Response, len(routes))
+	group, groupCtx := errgroup.WithContext(ctx)
+	for i, route := range routes {
+		contributions[i] = &message.Response{ShouldContinueHandling: true}
+		group.Go(func() error {
+			orchestrator.logger.InfoContext(groupCtx, "Running parallel handler", "route", route.Name)
+			if err := orchestrator.invoke(groupCtx, route.Handler, msg, contributions[i]); err != nil {
+				return err
+			}
+			claimComponents(contributions[i], 0, route.Name)
+			return nil
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		return err
+	}
+
+	for _, contribution := range contributions {
+		response.Merge(contribution)
+	}
+	return nil
+}
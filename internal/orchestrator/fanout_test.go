@@ -0,0 +1,112 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"rsandz/bearlawyergo/internal/message"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type parallelHandler struct {
+	name     string
+	delay    time.Duration
+	reaction string
+	err      error
+	running  *atomic.Int32
+	peak     *atomic.Int32
+}
+
+func (h *parallelHandler) Handle(ctx context.Context, m *message.Request, response *message.Response) error {
+	if h.running != nil {
+		n := h.running.Add(1)
+		defer h.running.Add(-1)
+		for {
+			peak := h.peak.Load()
+			if n <= peak || h.peak.CompareAndSwap(peak, n) {
+				break
+			}
+		}
+	}
+
+	select {
+	case <-time.After(h.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if h.err != nil {
+		return h.err
+	}
+	response.Annotate("last", h.name)
+	response.Annotate(h.name, "done")
+	response.AddOutput(message.NewReactionOutput(h.reaction), message.NewTextOutput(h.name))
+	return nil
+}
+
+func (h *parallelHandler) CanHandle(ctx context.Context, m *message.Request) bool {
+	return true
+}
+
+func (h *parallelHandler) Parallel() bool {
+	return true
+}
+
+func TestOrchestrator_Parallel_Merge(t *testing.T) {
+	var running, peak atomic.Int32
+	// The slowest handler has the highest priority so that merge order cannot follow completion order.
+	slow := &parallelHandler{name: "slow", delay: 50 * time.Millisecond, reaction: "🐻", running: &running, peak: &peak}
+	fast := &parallelHandler{name: "fast", delay: 10 * time.Millisecond, reaction: "⚖️", running: &running, peak: &peak}
+	final := &mockHandler{canHandle: true, shouldContinue: true}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	o := NewOrchestrator(newTestRouter(t, slow, fast, final), logger)
+
+	resp, err := o.Handle(context.Background(), &message.Request{})
+	if err != nil {
+		t.Fatalf("Handle() unexpected error = %v", err)
+	}
+
+	if peak.Load() != 2 {
+		t.Errorf("expected parallel handlers to run concurrently, peak concurrency %d", peak.Load())
+	}
+	expected := []message.Output{
+		message.NewReactionOutput("🐻"), message.NewTextOutput("slow"),
+		message.NewReactionOutput("⚖️"), message.NewTextOutput("fast"),
+	}
+	if !slices.Equal(resp.Outputs, expected) {
+		t.Errorf("expected outputs in route order, got %v", resp.Outputs)
+	}
+	if resp.Annotations["last"] != "fast" || resp.Annotations["slow"] != "done" || resp.Annotations["fast"] != "done" {
+		t.Errorf("unexpected annotations %v", resp.Annotations)
+	}
+	if !final.called || resp.ResponseMessage.Content != "mock response" {
+		t.Error("expected sequential handler to run after the parallel group")
+	}
+}
+
+func TestOrchestrator_Parallel_Error(t *testing.T) {
+	failure := errors.New("moderation failed")
+	failing := &parallelHandler{name: "failing", err: failure}
+	slow := &parallelHandler{name: "slow", delay: time.Minute}
+	final := &mockHandler{canHandle: true, shouldContinue: true}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	o := NewOrchestrator(newTestRouter(t, failing, slow, final), logger)
+
+	start := time.Now()
+	_, err := o.Handle(context.Background(), &message.Request{})
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected %v, got %v", failure, err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Error("expected the failure to cancel the remaining parallel handlers")
+	}
+	if final.called {
+		t.Error("handlers after a failed parallel group should not run")
+	}
+}
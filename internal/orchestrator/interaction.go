@@ -0,0 +1,89 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"rsandz/bearlawyergo/internal/message"
+	"time"
+)
+
+// FallbackRoute is the route name given to components added by the router's fallback handler.
+const FallbackRoute = "fallback"
+
+// InteractionHandler can be implemented by a handler that attaches components to its responses.
+// Interactions with those components are routed back to the handler.
+type InteractionHandler interface {
+	HandleInteraction(ctx context.Context, interaction *message.Interaction, response *message.Response) error
+}
+
+// HandleInteraction routes the use of a component to the handler that added it.
+// The interaction passes through the same middlewares as requests, and the handler runs with the same
+// panic recovery and deadline.
+func (orchestrator *Orchestrator) HandleInteraction(ctx context.Context, interaction *message.Interaction) (*message.Response, error) {
+	routeName := interaction.Component.Route
+	handler, ok := orchestrator.interactionHandler(routeName)
+	if !ok {
+		return nil, fmt.Errorf("%w: route %q", ErrNoInteractionHandler, routeName)
+	}
+
+	dispatch := HandlerFunc(func(ctx context.Context, msg *message.Request, response *message.Response) error {
+		orchestrator.logger.InfoContext(ctx, "Orchestrator received interaction", "route", routeName, "action", interaction.Component.Action)
+		start := len(response.Components)
+		invocation := &interactionInvocation{handler: handler, interaction: interaction, timeout: orchestrator.handlerTimeout}
+		if th, ok := handler.(TimeoutHandler); ok {
+			invocation.timeout = th.Timeout()
+		}
+		if err := orchestrator.invoke(ctx, invocation, msg, response); err != nil {
+			orchestrator.logger.ErrorContext(ctx, "Handler failed to handle interaction", "route", routeName, "error", err)
+			return err
+		}
+		claimComponents(response, start, routeName)
+		return nil
+	})
+
+	response := &message.Response{ShouldContinueHandling: true}
+	if err := chainMiddleware(dispatch, orchestrator.middlewares...).Handle(ctx, interaction.Request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// interactionHandler returns the interaction handler of the named route.
+func (orchestrator *Orchestrator) interactionHandler(routeName string) (InteractionHandler, bool) {
+	var handler Handler
+	if routeName == FallbackRoute {
+		handler = orchestrator.router.Fallback()
+	} else if route, ok := orchestrator.router.Route(routeName); ok {
+		handler = route.Handler
+	}
+	ih, ok := handler.(InteractionHandler)
+	return ih, ok
+}
+
+// claimComponents marks the components the route added, from index start on, as belonging to it.
+func claimComponents(response *message.Response, start int, routeName string) {
+	for i := start; i < len(response.Components); i++ {
+		if response.Components[i].Route == "" {
+			response.Components[i].Route = routeName
+		}
+	}
+}
+
+// interactionInvocation adapts an interaction to the Handler interface so that it can be invoked like a request.
+type interactionInvocation struct {
+	handler     InteractionHandler
+	interaction *message.Interaction
+	timeout     time.Duration
+}
+
+func (i *interactionInvocation) Handle(ctx context.Context, msg *message.Request, response *message.Response) error {
+	return i.handler.HandleInteraction(ctx, i.interaction, response)
+}
+
+func (i *interactionInvocation) CanHandle(ctx context.Context, msg *message.Request) bool {
+	return true
+}
+
+func (i *interactionInvocation) Timeout() time.Duration {
+	return i.timeout
+}
@@ -0,0 +1,108 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"rsandz/bearlawyergo/internal/message"
+)
+
+// pollHandler asks a question with a select menu and replies with the chosen option.
+type pollHandler struct {
+	interaction *message.Interaction
+	delay       time.Duration
+}
+
+func (h *pollHandler) Handle(ctx context.Context, m *message.Request, response *message.Response) error {
+	response.ResponseMessage = message.Message{Content: "Guilty or not guilty?"}
+	response.AddComponent(message.NewSelect("Your verdict", "vote", map[string]string{"case": "42"},
+		message.SelectOption{Label: "Guilty", Value: "guilty"},
+		message.SelectOption{Label: "Not guilty", Value: "not_guilty"},
+	))
+	return nil
+}
+
+func (h *pollHandler) CanHandle(ctx context.Context, m *message.Request) bool {
+	return true
+}
+
+func (h *pollHandler) HandleInteraction(ctx context.Context, interaction *message.Interaction, response *message.Response) error {
+	select {
+	case <-time.After(h.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	h.interaction = interaction
+	response.ResponseMessage = message.Message{Content: "Case " + interaction.Component.State["case"] + ": " + interaction.Values[0]}
+	response.AddComponent(message.NewButton("Appeal", "appeal", nil))
+	return nil
+}
+
+func TestOrchestrator_HandleInteraction(t *testing.T) {
+	poll := &pollHandler{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	var intercepted bool
+	middleware := func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, msg *message.Request, response *message.Response) error {
+			intercepted = true
+			return next.Handle(ctx, msg, response)
+		})
+	}
+	o := NewOrchestrator(newTestRouter(t, poll), logger, middleware)
+
+	resp, err := o.Handle(context.Background(), &message.Request{})
+	if err != nil {
+		t.Fatalf("Handle() unexpected error = %v", err)
+	}
+	if len(resp.Components) != 1 || resp.Components[0].Route != "handler-0" {
+		t.Fatalf("expected the component to be claimed by its route, got %+v", resp.Components)
+	}
+
+	intercepted = false
+	interaction := &message.Interaction{Request: &message.Request{}, Component: resp.Components[0], Values: []string{"guilty"}}
+	resp, err = o.HandleInteraction(context.Background(), interaction)
+	if err != nil {
+		t.Fatalf("HandleInteraction() unexpected error = %v", err)
+	}
+	if poll.interaction != interaction {
+		t.Error("expected the interaction to be routed to the handler that added the component")
+	}
+	if resp.ResponseMessage.Content != "Case 42: guilty" {
+		t.Errorf("unexpected response %q", resp.ResponseMessage.Content)
+	}
+	if len(resp.Components) != 1 || resp.Components[0].Route != "handler-0" {
+		t.Errorf("expected new components to be claimed by the route, got %+v", resp.Components)
+	}
+	if !intercepted {
+		t.Error("expected interactions to pass through middlewares")
+	}
+}
+
+func TestOrchestrator_HandleInteraction_Errors(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	o := NewOrchestrator(newTestRouter(t, &pollHandler{delay: time.Second}, &mockHandler{canHandle: true}), logger)
+	o.SetHandlerTimeout(10 * time.Millisecond)
+
+	tests := []struct {
+		name     string
+		route    string
+		expected error
+	}{
+		{name: "Unknown route", route: "missing", expected: ErrNoInteractionHandler},
+		{name: "Route without interaction handler", route: "handler-1", expected: ErrNoInteractionHandler},
+		{name: "Fallback without interaction handler", route: FallbackRoute, expected: ErrNoInteractionHandler},
+		{name: "Timeout", route: "handler-0", expected: ErrHandlerTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interaction := &message.Interaction{Request: &message.Request{}, Component: message.Component{Route: tt.route}}
+			if _, err := o.HandleInteraction(context.Background(), interaction); !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
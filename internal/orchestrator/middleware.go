@@ -0,0 +1,66 @@
+package orchestrator
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"rsandz/bearlawyergo/internal/logging"
+	"rsandz/bearlawyergo/internal/message"
+)
+
+// Middleware wraps the handler pipeline with cross-cutting behaviour such as tracing, timing or auth.
+// A middleware receives the next Handler in the chain and returns a Handler that calls it.
+type Middleware func(next Handler) Handler
+
+// HandlerFunc adapts a plain function to the Handler interface.
+// A HandlerFunc can always handle a message.
+type HandlerFunc func(ctx context.Context, msg *message.Request, response *message.Response) error
+
+func (f HandlerFunc) Handle(ctx context.Context, msg *message.Request, response *message.Response) error {
+	return f(ctx, msg, response)
+}
+
+func (f HandlerFunc) CanHandle(ctx context.Context, msg *message.Request) bool {
+	return true
+}
+
+// chainMiddleware wraps the handler with the middlewares so that the first middleware is the outermost.
+func chainMiddleware(handler Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// TraceIDMiddleware injects a random trace ID into the context unless one is already present.
+func TraceIDMiddleware(logger *slog.Logger) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, msg *message.Request, response *message.Response) error {
+			return next.Handle(WithTraceID(ctx, logger), msg, response)
+		})
+	}
+}
+
+// WithTraceID returns a context carrying a new random trace ID, unless ctx already has one.
+// Transports call this before handling a request so that their own logs share the request's trace ID.
+func WithTraceID(ctx context.Context, logger *slog.Logger) context.Context {
+	if _, ok := ctx.Value(logging.TraceIDKey).(string); ok {
+		return ctx
+	}
+	traceID, err := generateTraceID()
+	if err != nil {
+		// Proceed with an empty trace ID rather than failing the request.
+		logger.Error("Failed to generate trace ID", "error", err)
+	}
+	return context.WithValue(ctx, logging.TraceIDKey, traceID)
+}
+
+func generateTraceID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	return hex.EncodeToString(bytes), nil
+}
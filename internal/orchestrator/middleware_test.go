@@ -0,0 +1,91 @@
+package orchestrator
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"rsandz/bearlawyergo/internal/logging"
+	"rsandz/bearlawyergo/internal/message"
+	"slices"
+	"testing"
+)
+
+func recordingMiddleware(name string, calls *[]string) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, msg *message.Request, response *message.Response) error {
+			*calls = append(*calls, name)
+			return next.Handle(ctx, msg, response)
+		})
+	}
+}
+
+func TestOrchestrator_Middleware_Order(t *testing.T) {
+	var calls []string
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	o := NewOrchestrator(
+		newTestRouter(t, &mockHandler{canHandle: true, shouldContinue: true}),
+		logger,
+		recordingMiddleware("first", &calls),
+		recordingMiddleware("second", &calls),
+	)
+
+	if _, err := o.Handle(context.Background(), &message.Request{}); err != nil {
+		t.Fatalf("Handle() unexpected error = %v", err)
+	}
+
+	if !slices.Equal(calls, []string{"first", "second"}) {
+		t.Errorf("expected middlewares to run in registration order, got %v", calls)
+	}
+}
+
+func TestOrchestrator_Middleware_TraceID(t *testing.T) {
+	var traceID string
+	captureTraceID := func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, msg *message.Request, response *message.Response) error {
+			traceID, _ = ctx.Value(logging.TraceIDKey).(string)
+			return next.Handle(ctx, msg, response)
+		})
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	o := NewOrchestrator(newTestRouter(t, &mockHandler{canHandle: true, shouldContinue: true}), logger, captureTraceID)
+
+	if _, err := o.Handle(context.Background(), &message.Request{}); err != nil {
+		t.Fatalf("Handle() unexpected error = %v", err)
+	}
+	if traceID == "" {
+		t.Error("expected trace ID to be injected before user middlewares")
+	}
+
+	ctx := context.WithValue(context.Background(), logging.TraceIDKey, "existing")
+	if _, err := o.Handle(ctx, &message.Request{}); err != nil {
+		t.Fatalf("Handle() unexpected error = %v", err)
+	}
+	if traceID != "existing" {
+		t.Errorf("expected existing trace ID to be preserved, got %q", traceID)
+	}
+}
+
+func TestOrchestrator_Middleware_ShortCircuit(t *testing.T) {
+	h := &mockHandler{canHandle: true, shouldContinue: true}
+	deny := func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, msg *message.Request, response *message.Response) error {
+			response.ResponseMessage.Content = "denied"
+			return nil
+		})
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	o := NewOrchestrator(newTestRouter(t, h), logger, deny)
+
+	resp, err := o.Handle(context.Background(), &message.Request{})
+	if err != nil {
+		t.Fatalf("Handle() unexpected error = %v", err)
+	}
+	if h.called {
+		t.Error("handler should not be called when middleware short-circuits")
+	}
+	if resp.ResponseMessage.Content != "denied" {
+		t.Errorf("expected middleware response, got %q", resp.ResponseMessage.Content)
+	}
+}
@@ -2,77 +2,185 @@ package orchestrator
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"log/slog"
-	"rsandz/bearlawyergo/internal/logging"
+	"maps"
 	"rsandz/bearlawyergo/internal/message"
+	"runtime/debug"
+	"slices"
+	"time"
 )
 
-var (
-	ErrNoHandlerFound = errors.New("no handler found for message")
-)
+// DefaultHandlerTimeout bounds how long a single handler may run.
+const DefaultHandlerTimeout = 2 * time.Minute
 
 type Handler interface {
 	Handle(ctx context.Context, message *message.Request, response *message.Response) error
 	CanHandle(ctx context.Context, message *message.Request) bool
 }
 
+// TimeoutHandler can be implemented by a handler to override the orchestrator's handler timeout.
+type TimeoutHandler interface {
+	Timeout() time.Duration
+}
+
 type Orchestrator struct {
-	handlers []Handler
-	logger   *slog.Logger
+	router         *Router
+	pipeline       Handler
+	middlewares    []Middleware
+	handlerTimeout time.Duration
+	logger         *slog.Logger
 }
 
-func NewOrchestrator(handlers []Handler, logger *slog.Logger) *Orchestrator {
-	return &Orchestrator{
-		handlers: handlers,
-		logger:   logger,
+// Creates a new orchestrator.
+// Middlewares wrap every request in the order given, after the built-in trace ID middleware.
+func NewOrchestrator(router *Router, logger *slog.Logger, middlewares ...Middleware) *Orchestrator {
+	orchestrator := &Orchestrator{
+		router:         router,
+		handlerTimeout: DefaultHandlerTimeout,
+		logger:         logger,
 	}
+
+	orchestrator.middlewares = append([]Middleware{TraceIDMiddleware(logger)}, middlewares...)
+	orchestrator.pipeline = chainMiddleware(HandlerFunc(orchestrator.dispatch), orchestrator.middlewares...)
+	return orchestrator
+}
+
+// SetHandlerTimeout sets the deadline applied to each handler invocation.
+// A zero or negative timeout disables the deadline.
+func (orchestrator *Orchestrator) SetHandlerTimeout(timeout time.Duration) {
+	orchestrator.handlerTimeout = timeout
 }
 
 func (orchestrator *Orchestrator) Handle(ctx context.Context, msg *message.Request) (*message.Response, error) {
-	traceID, err := generateTraceID()
-	response := &message.Response{ShouldContinueHandling: true}
+	return orchestrator.HandleStream(ctx, msg, nil)
+}
 
-	if err != nil {
-		orchestrator.logger.Error("Failed to generate trace ID", "error", err)
-		// Fallback to no trace ID or some default? Let's proceed with empty or error
+// HandleStream handles the request while handlers write partial output to the stream.
+// The stream is closed once handling finishes, so callers should read it concurrently.
+// The returned response always holds the complete output.
+func (orchestrator *Orchestrator) HandleStream(ctx context.Context, msg *message.Request, stream *message.Stream) (*message.Response, error) {
+	if stream != nil {
+		defer stream.Close()
 	}
 
-	// Inject trace ID into context
-	ctx = context.WithValue(ctx, logging.TraceIDKey, traceID)
+	response := &message.Response{ShouldContinueHandling: true, Stream: stream}
+	if err := orchestrator.pipeline.Handle(ctx, msg, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
 
+// dispatch runs the matching routes against the request in priority order.
+// Adjacent parallel routes run together as a group.
+// If no route matches, the router's fallback handles the request.
+func (orchestrator *Orchestrator) dispatch(ctx context.Context, msg *message.Request, response *message.Response) error {
 	orchestrator.logger.InfoContext(ctx, "Orchestrator received message", "content", msg.RequestMessage.Content)
-	messageWasHandled := false
-	for _, h := range orchestrator.handlers {
-		if h.CanHandle(ctx, msg) {
-			orchestrator.logger.InfoContext(ctx, "Handler found for message", "handler_type", fmt.Sprintf("%T", h))
-			if err := h.Handle(ctx, msg, response); err != nil {
-				orchestrator.logger.ErrorContext(ctx, "Handler failed to handle message", "error", err)
-				return nil, err
-			}
-			messageWasHandled = true
 
-			if !response.ShouldContinueHandling {
-				orchestrator.logger.InfoContext(ctx, "Halting request handling early")
-				break
-			}
+	var matched []Route
+	for _, route := range orchestrator.router.Routes() {
+		if route.matches(ctx, msg) {
+			matched = append(matched, route)
 		}
 	}
 
-	if !messageWasHandled {
-		orchestrator.logger.WarnContext(ctx, "No handler found for message", "content", msg.RequestMessage.Content)
-		return nil, ErrNoHandlerFound
+	if len(matched) == 0 {
+		orchestrator.logger.InfoContext(ctx, "No route matched message, using fallback", "content", msg.RequestMessage.Content)
+		start := len(response.Components)
+		if err := orchestrator.invoke(ctx, orchestrator.router.Fallback(), msg, response); err != nil {
+			orchestrator.logger.ErrorContext(ctx, "Fallback failed to handle message", "error", err)
+			return err
+		}
+		claimComponents(response, start, FallbackRoute)
+		return nil
 	}
-	return response, nil
+
+	for i := 0; i < len(matched); {
+		route := matched[i]
+		if isParallel(route.Handler) {
+			end := i + 1
+			for end < len(matched) && isParallel(matched[end].Handler) {
+				end++
+			}
+			if err := orchestrator.runParallel(ctx, matched[i:end], msg, response); err != nil {
+				orchestrator.logger.ErrorContext(ctx, "Parallel handlers failed to handle message", "error", err)
+				return err
+			}
+			i = end
+		} else {
+			orchestrator.logger.InfoContext(ctx, "Handler found for message", "route", route.Name, "handler_type", fmt.Sprintf("%T", route.Handler))
+			start := len(response.Components)
+			if err := orchestrator.invoke(ctx, route.Handler, msg, response); err != nil {
+				orchestrator.logger.ErrorContext(ctx, "Handler failed to handle message", "route", route.Name, "error", err)
+				return err
+			}
+			claimComponents(response, start, route.Name)
+			i++
+		}
+
+		if !response.ShouldContinueHandling {
+			orchestrator.logger.InfoContext(ctx, "Halting request handling early", "route", route.Name)
+			break
+		}
+	}
+	return nil
 }
 
-func generateTraceID() (string, error) {
-	bytes := make([]byte, 16)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", fmt.Errorf("failed to read random bytes: %w", err)
+// invoke runs a single handler with panic recovery and a deadline.
+// The handler works on a copy of the response, including its annotations, outputs and components,
+// which is only written back once it returns, so a handler that outlives its deadline cannot race with the rest of the pipeline.
+func (orchestrator *Orchestrator) invoke(ctx context.Context, h Handler, msg *message.Request, response *message.Response) error {
+	handlerType := fmt.Sprintf("%T", h)
+	timeout := orchestrator.handlerTimeout
+	if th, ok := h.(TimeoutHandler); ok {
+		timeout = th.Timeout()
+	}
+
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	scratch := *response
+	scratch.Annotations = maps.Clone(response.Annotations)
+	scratch.Outputs = slices.Clone(response.Outputs)
+	scratch.Components = slices.Clone(response.Components)
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				stack := debug.Stack()
+				orchestrator.logger.ErrorContext(ctx, "Handler panicked", "handler_type", handlerType, "panic", r, "stack", string(stack))
+				done <- &HandlerError{HandlerType: handlerType, Err: ErrHandlerPanicked, PanicValue: r, Stack: stack}
+			}
+		}()
+		done <- h.Handle(ctx, msg, &scratch)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			// Handlers that honour the context surface the deadline themselves.
+			if errors.Is(err, context.DeadlineExceeded) && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return orchestrator.timeoutError(ctx, handlerType, timeout)
+			}
+			return err
+		}
+		*response = scratch
+		return nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return orchestrator.timeoutError(ctx, handlerType, timeout)
+		}
+		return ctx.Err()
 	}
-	return hex.EncodeToString(bytes), nil
+}
+
+func (orchestrator *Orchestrator) timeoutError(ctx context.Context, handlerType string, timeout time.Duration) error {
+	orchestrator.logger.ErrorContext(ctx, "Handler timed out", "handler_type", handlerType, "timeout", timeout)
+	return &HandlerError{HandlerType: handlerType, Err: ErrHandlerTimeout}
 }
@@ -2,10 +2,13 @@ package orchestrator
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"rsandz/bearlawyergo/internal/message"
 	"testing"
+	"time"
 )
 
 type mockHandler struct {
@@ -25,49 +28,56 @@ func (h *mockHandler) CanHandle(ctx context.Context, m *message.Request) bool {
 	return h.canHandle
 }
 
+// newTestRouter registers the handlers in order with descending priorities.
+func newTestRouter(t *testing.T, handlers ...Handler) *Router {
+	t.Helper()
+	router := NewRouter(nil)
+	for i, h := range handlers {
+		if err := router.Register(Route{Name: fmt.Sprintf("handler-%d", i), Priority: -i, Handler: h}); err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+	}
+	return router
+}
+
 func TestOrchestrator_Handle(t *testing.T) {
 	tests := []struct {
-		name        string
-		handlers    []Handler
-		wantErr     bool
-		expectedErr error
+		name            string
+		handlers        []Handler
+		expectedContent string
 	}{
 		{
-			name:        "no handlers",
-			handlers:    []Handler{},
-			wantErr:     true,
-			expectedErr: ErrNoHandlerFound,
+			name:            "no handlers",
+			handlers:        []Handler{},
+			expectedContent: DefaultFallbackReply,
 		},
 		{
 			name: "handler cannot handle",
 			handlers: []Handler{
 				&mockHandler{canHandle: false, shouldContinue: true},
 			},
-			wantErr:     true,
-			expectedErr: ErrNoHandlerFound,
+			expectedContent: DefaultFallbackReply,
 		},
 		{
 			name: "handler can handle",
 			handlers: []Handler{
 				&mockHandler{canHandle: true, shouldContinue: true},
 			},
-			wantErr:     false,
-			expectedErr: nil,
+			expectedContent: "mock response",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
-			o := NewOrchestrator(tt.handlers, logger)
+			o := NewOrchestrator(newTestRouter(t, tt.handlers...), logger)
 
-			_, err := o.Handle(context.Background(), &message.Request{})
-			if (err != nil) != tt.wantErr {
-				t.Errorf("Handle() error = %v, wantErr %v", err, tt.wantErr)
-				return
+			resp, err := o.Handle(context.Background(), &message.Request{})
+			if err != nil {
+				t.Fatalf("Handle() unexpected error = %v", err)
 			}
-			if tt.expectedErr != nil && err != tt.expectedErr {
-				t.Errorf("Handle() error = %v, expectedErr %v", err, tt.expectedErr)
+			if resp.ResponseMessage.Content != tt.expectedContent {
+				t.Errorf("Handle() content = %q, expected %q", resp.ResponseMessage.Content, tt.expectedContent)
 			}
 		})
 	}
@@ -79,7 +89,7 @@ func TestOrchestrator_Handle_MultipleHandlers(t *testing.T) {
 	h3 := &mockHandler{canHandle: true, shouldContinue: true}
 
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
-	o := NewOrchestrator([]Handler{h1, h2, h3}, logger)
+	o := NewOrchestrator(newTestRouter(t, h1, h2, h3), logger)
 
 	_, err := o.Handle(context.Background(), &message.Request{})
 	if err != nil {
@@ -96,3 +106,143 @@ func TestOrchestrator_Handle_MultipleHandlers(t *testing.T) {
 		t.Error("Handler 3 should NOT have been called")
 	}
 }
+
+type panickingHandler struct{}
+
+func (h *panickingHandler) Handle(ctx context.Context, m *message.Request, response *message.Response) error {
+	panic("boom")
+}
+
+func (h *panickingHandler) CanHandle(ctx context.Context, m *message.Request) bool {
+	return true
+}
+
+type blockingHandler struct {
+	timeout time.Duration
+}
+
+func (h *blockingHandler) Handle(ctx context.Context, m *message.Request, response *message.Response) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (h *blockingHandler) CanHandle(ctx context.Context, m *message.Request) bool {
+	return true
+}
+
+func (h *blockingHandler) Timeout() time.Duration {
+	return h.timeout
+}
+
+func TestOrchestrator_Handle_Panic(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	o := NewOrchestrator(newTestRouter(t, &panickingHandler{}), logger)
+
+	_, err := o.Handle(context.Background(), &message.Request{})
+	if !errors.Is(err, ErrHandlerPanicked) {
+		t.Fatalf("expected ErrHandlerPanicked, got %v", err)
+	}
+
+	var handlerErr *HandlerError
+	if !errors.As(err, &handlerErr) {
+		t.Fatalf("expected *HandlerError, got %T", err)
+	}
+	if handlerErr.HandlerType != "*orchestrator.panickingHandler" {
+		t.Errorf("unexpected handler type %q", handlerErr.HandlerType)
+	}
+	if handlerErr.PanicValue != "boom" {
+		t.Errorf("unexpected panic value %v", handlerErr.PanicValue)
+	}
+	if len(handlerErr.Stack) == 0 {
+		t.Error("expected stack to be captured")
+	}
+}
+
+func TestOrchestrator_Handle_Timeout(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("orchestrator timeout", func(t *testing.T) {
+		hangs := HandlerFunc(func(ctx context.Context, m *message.Request, r *message.Response) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		o := NewOrchestrator(newTestRouter(t, hangs), logger)
+		o.SetHandlerTimeout(10 * time.Millisecond)
+
+		_, err := o.Handle(context.Background(), &message.Request{})
+		if !errors.Is(err, ErrHandlerTimeout) {
+			t.Errorf("expected ErrHandlerTimeout, got %v", err)
+		}
+	})
+
+	t.Run("handler override", func(t *testing.T) {
+		o := NewOrchestrator(newTestRouter(t, &blockingHandler{timeout: 10 * time.Millisecond}), logger)
+
+		_, err := o.Handle(context.Background(), &message.Request{})
+		if !errors.Is(err, ErrHandlerTimeout) {
+			t.Errorf("expected ErrHandlerTimeout, got %v", err)
+		}
+	})
+}
+
+func TestOrchestrator_Handle_TimeoutIsolatesResponse(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	late := HandlerFunc(func(ctx context.Context, m *message.Request, r *message.Response) error {
+		defer close(finished)
+		<-release
+		r.Annotate("late", "true")
+		r.Outputs[0] = message.NewReactionOutput("late")
+		r.AddOutput(message.NewReactionOutput("late"))
+		return nil
+	})
+	o := NewOrchestrator(NewRouter(nil), logger)
+	o.SetHandlerTimeout(10 * time.Millisecond)
+
+	response := &message.Response{Outputs: make([]message.Output, 1, 4)}
+	response.Annotate("early", "true")
+	response.Outputs[0] = message.NewReactionOutput("early")
+	if err := o.invoke(context.Background(), late, &message.Request{}, response); !errors.Is(err, ErrHandlerTimeout) {
+		t.Fatalf("expected ErrHandlerTimeout, got %v", err)
+	}
+	close(release)
+	<-finished
+
+	if len(response.Annotations) != 1 || len(response.Outputs) != 1 || response.Outputs[:2][1].Text != "" || response.Outputs[0].Text != "early" {
+		t.Errorf("expected timed out handler not to change the response, got %+v", response)
+	}
+}
+
+func TestOrchestrator_HandleStream(t *testing.T) {
+	streaming := HandlerFunc(func(ctx context.Context, m *message.Request, r *message.Response) error {
+		if r.Stream == nil {
+			t.Fatal("expected stream on response")
+		}
+		if err := r.Stream.Send(ctx, "partial"); err != nil {
+			return err
+		}
+		r.ResponseMessage = message.Message{Content: "partial"}
+		return nil
+	})
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	o := NewOrchestrator(newTestRouter(t, streaming), logger)
+
+	stream := message.NewStream(1)
+	resp, err := o.HandleStream(context.Background(), &message.Request{}, stream)
+	if err != nil {
+		t.Fatalf("HandleStream() unexpected error = %v", err)
+	}
+
+	var chunks []string
+	for chunk := range stream.Chunks() {
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 1 || chunks[0] != "partial" {
+		t.Errorf("expected streamed chunk, got %v", chunks)
+	}
+	if resp.ResponseMessage.Content != "partial" {
+		t.Errorf("unexpected response %q", resp.ResponseMessage.Content)
+	}
+}
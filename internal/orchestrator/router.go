@@ -0,0 +1,140 @@
+package orchestrator
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"rsandz/bearlawyergo/internal/message"
+	"slices"
+	"strings"
+)
+
+// DefaultFallbackReply is sent when no route matches a message and no fallback handler is configured.
+const DefaultFallbackReply = "I'm not sure what to do with that."
+
+// Rule decides whether a route applies to a request.
+type Rule func(msg *message.Request) bool
+
+// Route registers a handler with the router.
+type Route struct {
+	// Name identifies the route in logs. Must be unique within a router.
+	Name string
+	// Priority orders routes. Higher priorities run first; ties keep registration order.
+	Priority int
+	// Handler handles requests matched by this route.
+	Handler Handler
+	// Rules must all match for the route to apply. A route with no rules matches every request.
+	Rules []Rule
+}
+
+func (route Route) matches(ctx context.Context, msg *message.Request) bool {
+	for _, rule := range route.Rules {
+		if !rule(msg) {
+			return false
+		}
+	}
+	return route.Handler.CanHandle(ctx, msg)
+}
+
+// Router holds the routes used by the orchestrator, ordered by priority.
+type Router struct {
+	routes   []Route
+	fallback Handler
+}
+
+// Creates a new router.
+// The fallback handles requests that no route matches. If nil, DefaultFallbackReply is sent.
+func NewRouter(fallback Handler) *Router {
+	if fallback == nil {
+		fallback = ReplyHandler(DefaultFallbackReply)
+	}
+	return &Router{fallback: fallback}
+}
+
+// Register adds a route to the router.
+func (r *Router) Register(route Route) error {
+	if route.Name == "" {
+		return errors.New("route name is required")
+	}
+	if route.Name == FallbackRoute {
+		return fmt.Errorf("route name %q is reserved for the fallback", route.Name)
+	}
+	if route.Handler == nil {
+		return fmt.Errorf("route %q has no handler", route.Name)
+	}
+	if slices.ContainsFunc(r.routes, func(existing Route) bool { return existing.Name == route.Name }) {
+		return fmt.Errorf("route %q is already registered", route.Name)
+	}
+
+	r.routes = append(r.routes, route)
+	slices.SortStableFunc(r.routes, func(a, b Route) int {
+		return cmp.Compare(b.Priority, a.Priority)
+	})
+	return nil
+}
+
+// Routes returns the registered routes in the order they run.
+func (r *Router) Routes() []Route {
+	return slices.Clone(r.routes)
+}
+
+// Route returns the route with the given name.
+func (r *Router) Route(name string) (Route, bool) {
+	idx := slices.IndexFunc(r.routes, func(route Route) bool { return route.Name == name })
+	if idx < 0 {
+		return Route{}, false
+	}
+	return r.routes[idx], true
+}
+
+// Fallback returns the handler used when no route matches.
+func (r *Router) Fallback() Handler {
+	return r.fallback
+}
+
+// ReplyHandler returns a handler that always replies with the given content and stops handling.
+func ReplyHandler(reply string) Handler {
+	return HandlerFunc(func(ctx context.Context, msg *message.Request, response *message.Response) error {
+		response.ResponseMessage = message.Message{Content: reply, Role: message.BotRole}
+		response.ShouldContinueHandling = false
+		return nil
+	})
+}
+
+// MatchRegex matches requests whose content matches the pattern.
+func MatchRegex(pattern *regexp.Regexp) Rule {
+	return func(msg *message.Request) bool {
+		return pattern.MatchString(msg.RequestMessage.Content)
+	}
+}
+
+// MatchChannels matches requests sent to one of the given channels, or to a thread in one of them.
+func MatchChannels(channels ...string) Rule {
+	return func(msg *message.Request) bool {
+		return slices.Contains(channels, msg.ChannelID) || (msg.ParentID != "" && slices.Contains(channels, msg.ParentID))
+	}
+}
+
+// MatchRoles matches requests from a user holding any of the given roles.
+func MatchRoles(roles ...string) Rule {
+	return func(msg *message.Request) bool {
+		return slices.ContainsFunc(msg.RequestMessage.Roles, func(role string) bool {
+			return slices.Contains(roles, role)
+		})
+	}
+}
+
+// MatchPrefix matches requests that invoke a prefix command such as "!roll".
+// The prefix must be followed by whitespace or the end of the message.
+func MatchPrefix(prefix string) Rule {
+	return func(msg *message.Request) bool {
+		content := strings.TrimSpace(msg.RequestMessage.Content)
+		rest, ok := strings.CutPrefix(content, prefix)
+		if !ok {
+			return false
+		}
+		return rest == "" || strings.TrimLeft(rest, " \t\n") != rest
+	}
+}
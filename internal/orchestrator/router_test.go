@@ -0,0 +1,133 @@
+package orchestrator
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"regexp"
+	"rsandz/bearlawyergo/internal/message"
+	"testing"
+)
+
+func TestRouter_Register(t *testing.T) {
+	router := NewRouter(nil)
+	h := &mockHandler{canHandle: true}
+
+	if err := router.Register(Route{Handler: h}); err == nil {
+		t.Error("expected error for route without name")
+	}
+	if err := router.Register(Route{Name: "nil"}); err == nil {
+		t.Error("expected error for route without handler")
+	}
+	if err := router.Register(Route{Name: "dup", Handler: h}); err != nil {
+		t.Fatalf("Register() unexpected error = %v", err)
+	}
+	if err := router.Register(Route{Name: "dup", Handler: h}); err == nil {
+		t.Error("expected error for duplicate route name")
+	}
+	if err := router.Register(Route{Name: FallbackRoute, Handler: h}); err == nil {
+		t.Error("expected error for reserved route name")
+	}
+	if route, ok := router.Route("dup"); !ok || route.Handler != h {
+		t.Error("expected registered route to be found by name")
+	}
+}
+
+func TestRouter_Priority(t *testing.T) {
+	router := NewRouter(nil)
+	for _, route := range []Route{
+		{Name: "low", Priority: 0, Handler: &mockHandler{}},
+		{Name: "high", Priority: 10, Handler: &mockHandler{}},
+		{Name: "low-2", Priority: 0, Handler: &mockHandler{}},
+		// Extreme priorities overflow if compared by subtraction.
+		{Name: "last", Priority: math.MinInt, Handler: &mockHandler{}},
+		{Name: "first", Priority: math.MaxInt, Handler: &mockHandler{}},
+	} {
+		if err := router.Register(route); err != nil {
+			t.Fatalf("Register() unexpected error = %v", err)
+		}
+	}
+
+	var names []string
+	for _, route := range router.Routes() {
+		names = append(names, route.Name)
+	}
+	expected := []string{"first", "high", "low", "low-2", "last"}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("expected route order %v, got %v", expected, names)
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	request := &message.Request{
+		RequestMessage: message.Message{Content: "!roll 2d6", Roles: []string{"mod"}},
+		ChannelID:      "general",
+	}
+
+	threadRequest := &message.Request{ChannelID: "thread", ThreadID: "thread", ParentID: "general"}
+
+	tests := []struct {
+		name     string
+		rule     Rule
+		request  *message.Request
+		expected bool
+	}{
+		{name: "regex match", rule: MatchRegex(regexp.MustCompile(`\d+d\d+`)), expected: true},
+		{name: "regex no match", rule: MatchRegex(regexp.MustCompile(`^hello`)), expected: false},
+		{name: "channel allowed", rule: MatchChannels("general", "random"), expected: true},
+		{name: "channel not allowed", rule: MatchChannels("random"), expected: false},
+		{name: "thread in allowed channel", rule: MatchChannels("general"), request: threadRequest, expected: true},
+		{name: "role held", rule: MatchRoles("admin", "mod"), expected: true},
+		{name: "role not held", rule: MatchRoles("admin"), expected: false},
+		{name: "prefix command", rule: MatchPrefix("!roll"), expected: true},
+		{name: "prefix partial word", rule: MatchPrefix("!rol"), expected: false},
+		{name: "prefix other command", rule: MatchPrefix("!help"), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request
+			if tt.request != nil {
+				req = tt.request
+			}
+			if got := tt.rule(req); got != tt.expected {
+				t.Errorf("rule() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_Routing(t *testing.T) {
+	roll := &mockHandler{canHandle: true, shouldContinue: false}
+	chat := &mockHandler{canHandle: true, shouldContinue: false}
+	fallback := &mockHandler{canHandle: true}
+
+	router := NewRouter(fallback)
+	if err := router.Register(Route{Name: "chat", Handler: chat, Rules: []Rule{MatchChannels("general")}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := router.Register(Route{Name: "roll", Priority: 10, Handler: roll, Rules: []Rule{MatchPrefix("!roll")}}); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	o := NewOrchestrator(router, logger)
+
+	if _, err := o.Handle(context.Background(), &message.Request{RequestMessage: message.Message{Content: "!roll"}, ChannelID: "general"}); err != nil {
+		t.Fatalf("Handle() unexpected error = %v", err)
+	}
+	if !roll.called || chat.called || fallback.called {
+		t.Errorf("expected only roll to be called, got roll=%v chat=%v fallback=%v", roll.called, chat.called, fallback.called)
+	}
+
+	roll.called = false
+	if _, err := o.Handle(context.Background(), &message.Request{RequestMessage: message.Message{Content: "hi"}, ChannelID: "other"}); err != nil {
+		t.Fatalf("Handle() unexpected error = %v", err)
+	}
+	if roll.called || chat.called || !fallback.called {
+		t.Errorf("expected only fallback to be called, got roll=%v chat=%v fallback=%v", roll.called, chat.called, fallback.called)
+	}
+}
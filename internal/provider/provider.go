@@ -0,0 +1,144 @@
+// Package provider builds language models from config.
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"rsandz/bearlawyergo/internal/config"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/anthropic"
+	"github.com/tmc/langchaingo/llms/googleai"
+	"github.com/tmc/langchaingo/llms/ollama"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// compatibleToken is sent to OpenAI compatible servers that are configured without an API key,
+// since the OpenAI client refuses to start without one.
+const compatibleToken = "unused"
+
+// New builds the language model described by cfg, followed by its fallbacks.
+// Failed requests are retried and failed over as configured by cfg.Retry.
+// Each model's temperature and max tokens apply to every call unless the call sets its own.
+func New(ctx context.Context, cfg config.LLM, logger *slog.Logger) (llms.Model, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	var backends []Backend
+	for _, modelConfig := range append([]config.LLM{cfg}, cfg.Fallbacks...) {
+		model, err := newModel(ctx, modelConfig)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, Backend{Name: backendName(modelConfig), Model: model})
+	}
+	return NewResilient(backends, cfg.Retry, logger), nil
+}
+
+// newModel builds a single model, ignoring the config's retry and fallbacks.
+func newModel(ctx context.Context, cfg config.LLM) (llms.Model, error) {
+	client := &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: hintTransport{base: http.DefaultTransport},
+	}
+
+	var model llms.Model
+	var err error
+	switch cfg.Provider {
+	case config.OpenAIProvider, config.OpenAICompatibleProvider:
+		model, err = newOpenAI(cfg, client)
+	case config.OllamaProvider:
+		opts := []ollama.Option{ollama.WithHTTPClient(client)}
+		if cfg.Model != "" {
+			opts = append(opts, ollama.WithModel(cfg.Model))
+		}
+		if cfg.BaseURL != "" {
+			opts = append(opts, ollama.WithServerURL(cfg.BaseURL))
+		}
+		model, err = ollama.New(opts...)
+	case config.AnthropicProvider:
+		opts := []anthropic.Option{anthropic.WithHTTPClient(client)}
+		if cfg.Model != "" {
+			opts = append(opts, anthropic.WithModel(cfg.Model))
+		}
+		if cfg.BaseURL != "" {
+			opts = append(opts, anthropic.WithBaseURL(cfg.BaseURL))
+		}
+		if cfg.APIKey != "" {
+			opts = append(opts, anthropic.WithToken(cfg.APIKey))
+		}
+		model, err = anthropic.New(opts...)
+	case config.GoogleAIProvider:
+		opts := []googleai.Option{googleai.WithHTTPClient(client), googleai.WithRest()}
+		if cfg.Model != "" {
+			opts = append(opts, googleai.WithDefaultModel(cfg.Model))
+		}
+		if cfg.APIKey != "" {
+			opts = append(opts, googleai.WithAPIKey(cfg.APIKey))
+		}
+		model, err = googleai.New(ctx, opts...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s model: %w", cfg.Provider, err)
+	}
+	return withDefaults(model, cfg), nil
+}
+
+// backendName identifies the model in logs.
+func backendName(cfg config.LLM) string {
+	if cfg.Model == "" {
+		return cfg.Provider
+	}
+	return cfg.Provider + "/" + cfg.Model
+}
+
+func newOpenAI(cfg config.LLM, client *http.Client) (llms.Model, error) {
+	opts := []openai.Option{openai.WithHTTPClient(client)}
+	if cfg.Model != "" {
+		opts = append(opts, openai.WithModel(cfg.Model))
+	}
+	if cfg.BaseURL != "" {
+		opts = append(opts, openai.WithBaseURL(cfg.BaseURL))
+	}
+	token := cfg.APIKey
+	if token == "" && cfg.Provider == config.OpenAICompatibleProvider {
+		token = compatibleToken
+	}
+	if token != "" {
+		opts = append(opts, openai.WithToken(token))
+	}
+	return openai.New(opts...)
+}
+
+// defaultsModel applies default call options before the caller's own.
+type defaultsModel struct {
+	llms.Model
+	defaults []llms.CallOption
+}
+
+// withDefaults wraps the model so that calls use the config's temperature and max tokens by default.
+func withDefaults(model llms.Model, cfg config.LLM) llms.Model {
+	var defaults []llms.CallOption
+	if cfg.Temperature != nil {
+		defaults = append(defaults, llms.WithTemperature(*cfg.Temperature))
+	}
+	if cfg.MaxTokens > 0 {
+		defaults = append(defaults, llms.WithMaxTokens(cfg.MaxTokens))
+	}
+	if len(defaults) == 0 {
+		return model
+	}
+	return &defaultsModel{Model: model, defaults: defaults}
+}
+
+func (m *defaultsModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	return m.Model.GenerateContent(ctx, messages, append(m.defaults[:len(m.defaults):len(m.defaults)], options...)...)
+}
+
+func (m *defaultsModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
@@ -0,0 +1,97 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rsandz/bearlawyergo/internal/config"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// newCompatibleServer returns a fake OpenAI compatible server that answers every chat completion with "Objection"
+// and records the request body.
+func newCompatibleServer(t *testing.T, body *map[string]any) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": "1", "object": "chat.completion", "choices": [{"index": 0, "message": {"role": "assistant", "content": "Objection"}, "finish_reason": "stop"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewOpenAICompatible(t *testing.T) {
+	var body map[string]any
+	server := newCompatibleServer(t, &body)
+	temperature := 0.2
+
+	model, err := newModel(context.Background(), config.LLM{
+		Provider:    config.OpenAICompatibleProvider,
+		Model:       "mock-model",
+		BaseURL:     server.URL,
+		Temperature: &temperature,
+		MaxTokens:   64,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := llms.GenerateFromSinglePrompt(context.Background(), model, "Order?", llms.WithMaxTokens(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "Objection" {
+		t.Errorf("reply = %q, want %q", reply, "Objection")
+	}
+	if body["model"] != "mock-model" {
+		t.Errorf("model = %v, want mock-model", body["model"])
+	}
+	if body["temperature"] != 0.2 {
+		t.Errorf("temperature = %v, want the configured 0.2", body["temperature"])
+	}
+	// The call's own max tokens override the configured default.
+	if body["max_completion_tokens"] != float64(16) && body["max_tokens"] != float64(16) {
+		t.Errorf("body = %v, want max tokens 16", body)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.LLM
+		wantErr bool
+	}{
+		{name: "ollama", cfg: config.LLM{Provider: config.OllamaProvider, Model: "llama3.2", BaseURL: "http://localhost:11434"}},
+		{name: "anthropic", cfg: config.LLM{Provider: config.AnthropicProvider, APIKey: "key"}},
+		{name: "openai", cfg: config.LLM{Provider: config.OpenAIProvider, APIKey: "key"}},
+		{
+			name: "with fallback",
+			cfg: config.LLM{
+				Provider:  config.OpenAIProvider,
+				APIKey:    "key",
+				Fallbacks: []config.LLM{{Provider: config.OllamaProvider, Model: "llama3.2"}},
+			},
+		},
+		{name: "invalid fallback", cfg: config.LLM{Provider: config.OpenAIProvider, APIKey: "key", Fallbacks: []config.LLM{{Provider: config.OllamaProvider}}}, wantErr: true},
+		{name: "compatible without base url", cfg: config.LLM{Provider: config.OpenAICompatibleProvider}, wantErr: true},
+		{name: "unknown provider", cfg: config.LLM{Provider: "carrier-pigeon"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Retry = config.DefaultRetry
+			_, err := New(context.Background(), tt.cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
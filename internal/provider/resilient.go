@@ -0,0 +1,290 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"rsandz/bearlawyergo/internal/config"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ErrCircuitOpen is returned for a provider that is skipped because its recent requests failed.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// Backend is a model that Resilient can send requests to.
+type Backend struct {
+	// Name identifies the backend in logs, e.g. "openai/gpt-4o".
+	Name  string
+	Model llms.Model
+}
+
+// Resilient is a model that retries transient failures with jittered exponential backoff and fails over to the
+// next backend once a backend keeps failing. Each backend has a circuit breaker: after too many failed requests
+// in a row it is skipped until a cooldown has passed, then a single request is let through to test it.
+type Resilient struct {
+	backends []*breaker
+	retry    config.Retry
+	logger   *slog.Logger
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Creates a new resilient model that prefers the backends in order.
+func NewResilient(backends []Backend, retry config.Retry, logger *slog.Logger) *Resilient {
+	r := &Resilient{
+		retry:  retry,
+		logger: logger,
+		now:    time.Now,
+		sleep:  sleep,
+	}
+	for _, backend := range backends {
+		r.backends = append(r.backends, &breaker{Backend: backend})
+	}
+	return r
+}
+
+func (r *Resilient) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	var errs []error
+	for i, b := range r.backends {
+		if !b.allow(r.now(), r.retry.Cooldown, r.logger) {
+			errs = append(errs, fmt.Errorf("%s: %w", b.Name, ErrCircuitOpen))
+			continue
+		}
+
+		resp, streamed, err := r.generate(ctx, b, messages, options)
+		if err == nil {
+			b.succeed(r.logger)
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			// The caller gave up, which says nothing about the backend.
+			b.release()
+			return nil, err
+		}
+		b.fail(r.now(), r.retry.FailureThreshold, r.logger)
+		errs = append(errs, fmt.Errorf("%s: %w", b.Name, err))
+		if streamed {
+			// The caller has already shown part of this backend's answer, so another backend cannot take over.
+			return nil, err
+		}
+		if i < len(r.backends)-1 {
+			r.logger.WarnContext(ctx, "LLM backend failed, failing over", "backend", b.Name, "next", r.backends[i+1].Name, "error", err)
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (r *Resilient) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, r, prompt, options...)
+}
+
+// generate sends the request to the backend, retrying transient failures until none are left.
+// Reports whether any of the response was streamed, after which the request is not retried.
+func (r *Resilient) generate(ctx context.Context, b *breaker, messages []llms.MessageContent, options []llms.CallOption) (*llms.ContentResponse, bool, error) {
+	streamed := false
+	var callOptions llms.CallOptions
+	for _, option := range options {
+		option(&callOptions)
+	}
+	if stream := callOptions.StreamingFunc; stream != nil {
+		options = append(options[:len(options):len(options)], llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+			streamed = true
+			return stream(ctx, chunk)
+		}))
+	}
+
+	for attempt := 1; ; attempt++ {
+		hint := &retryHint{}
+		resp, err := b.Model.GenerateContent(context.WithValue(ctx, retryHintKey{}, hint), messages, options...)
+		if err == nil {
+			return resp, false, nil
+		}
+		if streamed || attempt >= r.retry.MaxAttempts || ctx.Err() != nil || !isTransient(err, hint) {
+			return nil, streamed, err
+		}
+		delay, ok := r.backoff(attempt, hint.retryAfter)
+		if !ok {
+			return nil, false, fmt.Errorf("%w (provider asked to retry after %s)", err, hint.retryAfter)
+		}
+		r.logger.InfoContext(ctx, "Retrying LLM request", "backend", b.Name, "attempt", attempt, "delay", delay, "error", err)
+		if err := r.sleep(ctx, delay); err != nil {
+			return nil, false, err
+		}
+	}
+}
+
+// backoff returns how long to wait before retrying the attempt: the provider's Retry-After if it sent one,
+// otherwise an exponentially growing delay with jitter. Reports false if the provider asked to wait longer than allowed.
+func (r *Resilient) backoff(attempt int, retryAfter time.Duration) (time.Duration, bool) {
+	if retryAfter > 0 {
+		return retryAfter, retryAfter <= r.retry.MaxDelay
+	}
+	delay := r.retry.BaseDelay << (attempt - 1)
+	if delay > r.retry.MaxDelay || delay <= 0 {
+		delay = r.retry.MaxDelay
+	}
+	// Equal jitter: wait at least half the delay so that retries still back off.
+	half := delay / 2
+	if half <= 0 {
+		return delay, true
+	}
+	return half + rand.N(half+1), true
+}
+
+// isTransient reports whether the failure may go away if the request is retried:
+// rate limits, server errors and timeouts.
+func isTransient(err error, hint *retryHint) bool {
+	if hint.status == http.StatusTooManyRequests || hint.status >= http.StatusInternalServerError {
+		return true
+	}
+	if llms.IsRateLimitError(err) || llms.IsTimeoutError(err) || llms.IsProviderUnavailableError(err) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type circuitState string
+
+const (
+	circuitClosed   circuitState = "closed"
+	circuitOpen     circuitState = "open"
+	circuitHalfOpen circuitState = "half-open"
+)
+
+// breaker tracks the failures of a backend and decides whether requests may be sent to it.
+type breaker struct {
+	Backend
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+	// probing is set while the single request of a half-open circuit is in flight.
+	probing bool
+}
+
+// allow reports whether a request may be sent to the backend.
+func (b *breaker) allow(now time.Time, cooldown time.Duration, logger *slog.Logger) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case circuitOpen:
+		if now.Sub(b.openedAt) < cooldown {
+			return false
+		}
+		b.transition(circuitHalfOpen, logger)
+		b.probing = true
+		return true
+	case circuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *breaker) succeed(logger *slog.Logger) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+	b.transition(circuitClosed, logger)
+}
+
+func (b *breaker) fail(now time.Time, threshold int, logger *slog.Logger) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.state == circuitHalfOpen || b.failures >= threshold {
+		b.openedAt = now
+		b.transition(circuitOpen, logger)
+	}
+}
+
+// release lets another request test a half-open circuit after a request ended without a verdict.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// transition changes the circuit's state, logging the change.
+func (b *breaker) transition(state circuitState, logger *slog.Logger) {
+	if b.state == "" {
+		b.state = circuitClosed
+	}
+	if b.state == state {
+		return
+	}
+	logger.Warn("LLM backend circuit changed state", "backend", b.Name, "from", b.state, "to", state, "failures", b.failures)
+	b.state = state
+}
+
+// retryHint carries what the provider's HTTP response said about retrying back to Resilient,
+// since the provider clients do not expose status codes or headers in their errors.
+type retryHint struct {
+	status     int
+	retryAfter time.Duration
+}
+
+type retryHintKey struct{}
+
+// hintTransport records the status and Retry-After header of failed responses in the request's retryHint.
+type hintTransport struct {
+	base http.RoundTripper
+}
+
+func (t hintTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode < http.StatusBadRequest {
+		return resp, err
+	}
+	if hint, ok := req.Context().Value(retryHintKey{}).(*retryHint); ok {
+		hint.status = resp.StatusCode
+		hint.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return resp, nil
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date. Returns zero if absent or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,247 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rsandz/bearlawyergo/internal/config"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// fakeModel answers with its reply once its errors have been returned, in order.
+type fakeModel struct {
+	errs   []error
+	reply  string
+	stream string
+	calls  int
+}
+
+func (m *fakeModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	m.calls++
+	var opts llms.CallOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	if m.stream != "" && opts.StreamingFunc != nil {
+		if err := opts.StreamingFunc(ctx, []byte(m.stream)); err != nil {
+			return nil, err
+		}
+	}
+	if len(m.errs) > 0 {
+		err := m.errs[0]
+		m.errs = m.errs[1:]
+		return nil, err
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: m.reply}}}, nil
+}
+
+func (m *fakeModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+var (
+	errRateLimited = llms.NewError(llms.ErrCodeRateLimit, "fake", "slow down")
+	errBadRequest  = llms.NewError(llms.ErrCodeInvalidRequest, "fake", "bad request")
+)
+
+// newTestResilient returns a resilient model over the models, with a fake clock and recorded sleeps.
+func newTestResilient(retry config.Retry, models ...*fakeModel) (*Resilient, *time.Time, *[]time.Duration) {
+	var backends []Backend
+	for i, model := range models {
+		backends = append(backends, Backend{Name: string(rune('a' + i)), Model: model})
+	}
+	r := NewResilient(backends, retry, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var sleeps []time.Duration
+	r.now = func() time.Time { return now }
+	r.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return r, &now, &sleeps
+}
+
+func TestResilientRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		errs         []error
+		wantErr      bool
+		wantCalls    int
+		wantSleeps   int
+		wantFallback bool
+	}{
+		{name: "success", wantCalls: 1},
+		{name: "transient failure is retried", errs: []error{errRateLimited, errRateLimited}, wantCalls: 3, wantSleeps: 2},
+		{name: "retries run out and fail over", errs: []error{errRateLimited, errRateLimited, errRateLimited}, wantCalls: 3, wantSleeps: 2, wantFallback: true},
+		{name: "permanent failure fails over at once", errs: []error{errBadRequest}, wantCalls: 1, wantFallback: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &fakeModel{errs: tt.errs, reply: "primary"}
+			secondary := &fakeModel{reply: "secondary"}
+			r, _, sleeps := newTestResilient(config.DefaultRetry, primary, secondary)
+
+			reply, err := llms.GenerateFromSinglePrompt(context.Background(), r, "Order?")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := "primary"
+			if tt.wantFallback {
+				want = "secondary"
+			}
+			if reply != want {
+				t.Errorf("reply = %q, want %q", reply, want)
+			}
+			if primary.calls != tt.wantCalls {
+				t.Errorf("primary calls = %d, want %d", primary.calls, tt.wantCalls)
+			}
+			if len(*sleeps) != tt.wantSleeps {
+				t.Errorf("sleeps = %v, want %d", *sleeps, tt.wantSleeps)
+			}
+			for i, d := range *sleeps {
+				maxDelay := config.DefaultRetry.BaseDelay << i
+				if d < maxDelay/2 || d > maxDelay {
+					t.Errorf("sleep %d = %s, want between %s and %s", i, d, maxDelay/2, maxDelay)
+				}
+			}
+		})
+	}
+}
+
+func TestResilientAllFail(t *testing.T) {
+	r, _, _ := newTestResilient(config.DefaultRetry, &fakeModel{errs: []error{errBadRequest}}, &fakeModel{errs: []error{errBadRequest}})
+	_, err := llms.GenerateFromSinglePrompt(context.Background(), r, "Order?")
+	if !errors.Is(err, errBadRequest) {
+		t.Errorf("error = %v, want the backends' errors", err)
+	}
+}
+
+func TestResilientStreamed(t *testing.T) {
+	primary := &fakeModel{errs: []error{errRateLimited}, stream: "Order "}
+	secondary := &fakeModel{reply: "secondary"}
+	r, _, _ := newTestResilient(config.DefaultRetry, primary, secondary)
+
+	var chunks string
+	_, err := r.GenerateContent(context.Background(), nil, llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+		chunks += string(chunk)
+		return nil
+	}))
+	if !errors.Is(err, errRateLimited) {
+		t.Errorf("error = %v, want %v", err, errRateLimited)
+	}
+	if primary.calls != 1 || secondary.calls != 0 {
+		t.Errorf("calls = %d, %d, want no retry or failover after streaming", primary.calls, secondary.calls)
+	}
+	if chunks != "Order " {
+		t.Errorf("chunks = %q, want %q", chunks, "Order ")
+	}
+}
+
+func TestResilientCircuitBreaker(t *testing.T) {
+	retry := config.Retry{MaxAttempts: 1, FailureThreshold: 2, Cooldown: time.Minute}
+	primary := &fakeModel{errs: []error{errBadRequest, errBadRequest, errBadRequest}, reply: "primary"}
+	secondary := &fakeModel{reply: "secondary"}
+	r, now, _ := newTestResilient(retry, primary, secondary)
+
+	generate := func() string {
+		t.Helper()
+		reply, err := llms.GenerateFromSinglePrompt(context.Background(), r, "Order?")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+
+	// Two failures open the circuit, so the third request skips the primary.
+	for range 3 {
+		if reply := generate(); reply != "secondary" {
+			t.Fatalf("reply = %q, want secondary", reply)
+		}
+	}
+	if primary.calls != 2 {
+		t.Fatalf("primary calls = %d, want 2", primary.calls)
+	}
+
+	// After the cooldown a failed probe opens the circuit again.
+	*now = now.Add(time.Minute)
+	generate()
+	if primary.calls != 3 {
+		t.Fatalf("primary calls = %d, want a probe", primary.calls)
+	}
+	generate()
+	if primary.calls != 3 {
+		t.Fatalf("primary calls = %d, want the circuit open again", primary.calls)
+	}
+
+	// A successful probe closes the circuit.
+	*now = now.Add(time.Minute)
+	if reply := generate(); reply != "primary" {
+		t.Fatalf("reply = %q, want primary", reply)
+	}
+	if reply := generate(); reply != "primary" {
+		t.Fatalf("reply = %q, want primary once closed", reply)
+	}
+}
+
+func TestResilientRetryAfter(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		if calls == 1 {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"message": "slow down"}}`))
+			return
+		}
+		w.Write([]byte(`{"id": "1", "object": "chat.completion", "choices": [{"index": 0, "message": {"role": "assistant", "content": "Objection"}, "finish_reason": "stop"}]}`))
+	}))
+	defer server.Close()
+
+	model, err := newModel(context.Background(), config.LLM{Provider: config.OpenAICompatibleProvider, BaseURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewResilient([]Backend{{Name: "mock", Model: model}}, config.DefaultRetry, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	var sleeps []time.Duration
+	r.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+
+	reply, err := llms.GenerateFromSinglePrompt(context.Background(), r, "Order?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "Objection" {
+		t.Errorf("reply = %q, want Objection", reply)
+	}
+	if len(sleeps) != 1 || sleeps[0] != 2*time.Second {
+		t.Errorf("sleeps = %v, want the 2s from Retry-After", sleeps)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "3", want: 3 * time.Second},
+		{value: now.Add(5 * time.Second).Format(http.TimeFormat), want: 5 * time.Second},
+		{value: "soon", want: 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+)
+
+const (
+	maxDice  = 100
+	maxSides = 1000
+)
+
+// DiceTool rolls dice for the model, e.g. for games or settling disputes.
+type DiceTool struct {
+	roll func(sides int) int
+}
+
+// NewDiceTool creates a new DiceTool.
+func NewDiceTool() *DiceTool {
+	return &DiceTool{roll: func(sides int) int { return rand.IntN(sides) + 1 }}
+}
+
+func (t *DiceTool) Name() string {
+	return "roll_dice"
+}
+
+func (t *DiceTool) Description() string {
+	return "Rolls dice and returns each roll and the total. Use this whenever the user asks for a dice roll or a random number."
+}
+
+func (t *DiceTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"count": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Number of dice to roll, between 1 and %d.", maxDice),
+			},
+			"sides": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Number of sides on each die, between 2 and %d.", maxSides),
+			},
+		},
+		"required": []string{"count", "sides"},
+	}
+}
+
+func (t *DiceTool) Execute(ctx context.Context, args string) (string, error) {
+	var params struct {
+		Count int `json:"count"`
+		Sides int `json:"sides"`
+	}
+	if err := json.Unmarshal([]byte(args), &params); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	if params.Count < 1 || params.Count > maxDice {
+		return "", fmt.Errorf("count must be between 1 and %d", maxDice)
+	}
+	if params.Sides < 2 || params.Sides > maxSides {
+		return "", fmt.Errorf("sides must be between 2 and %d", maxSides)
+	}
+
+	rolls := make([]string, params.Count)
+	total := 0
+	for i := range rolls {
+		roll := t.roll(params.Sides)
+		total += roll
+		rolls[i] = fmt.Sprint(roll)
+	}
+	return fmt.Sprintf("Rolled %dd%d: %s (total %d)", params.Count, params.Sides, strings.Join(rolls, ", "), total), nil
+}
@@ -0,0 +1,109 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ErrToolNotFound is returned when the model calls a tool that is not registered.
+var ErrToolNotFound = errors.New("tool not found")
+
+// Tool is a function the model can call while generating a response.
+type Tool interface {
+	// Name is the unique name the model uses to call the tool.
+	Name() string
+	// Description tells the model what the tool does and when to use it.
+	Description() string
+	// Parameters is the JSON schema of the tool's arguments.
+	Parameters() map[string]any
+	// Execute runs the tool with the JSON encoded arguments chosen by the model and returns the result for the model.
+	Execute(ctx context.Context, args string) (string, error)
+}
+
+// Registry is a thread-safe set of tools available to the model.
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+}
+
+// NewRegistry creates a new Registry with the given tools.
+func NewRegistry(tools ...Tool) (*Registry, error) {
+	r := &Registry{tools: make(map[string]Tool)}
+	for _, t := range tools {
+		if err := r.Register(t); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register adds a tool to the registry.
+func (r *Registry) Register(t Tool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t.Name() == "" {
+		return errors.New("tool name is required")
+	}
+	if _, ok := r.tools[t.Name()]; ok {
+		return fmt.Errorf("tool %q is already registered", t.Name())
+	}
+	r.tools[t.Name()] = t
+	return nil
+}
+
+// Get returns the tool with the given name.
+func (r *Registry) Get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tools[name]
+	return t, ok
+}
+
+// Len returns the number of registered tools.
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.tools)
+}
+
+// Definitions returns the tool definitions to send to the model, sorted by name.
+func (r *Registry) Definitions() []llms.Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	definitions := make([]llms.Tool, 0, len(r.tools))
+	for _, t := range r.tools {
+		definitions = append(definitions, llms.Tool{
+			Type: "function",
+			Function: &llms.FunctionDefinition{
+				Name:        t.Name(),
+				Description: t.Description(),
+				Parameters:  t.Parameters(),
+			},
+		})
+	}
+	slices.SortFunc(definitions, func(a, b llms.Tool) int {
+		if a.Function.Name < b.Function.Name {
+			return -1
+		}
+		if a.Function.Name > b.Function.Name {
+			return 1
+		}
+		return 0
+	})
+	return definitions
+}
+
+// Execute runs the named tool.
+func (r *Registry) Execute(ctx context.Context, name string, args string) (string, error) {
+	t, ok := r.Get(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrToolNotFound, name)
+	}
+	return t.Execute(ctx, args)
+}
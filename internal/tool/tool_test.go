@@ -0,0 +1,60 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	registry, err := NewRegistry(NewDiceTool())
+	if err != nil {
+		t.Fatalf("NewRegistry() unexpected error = %v", err)
+	}
+
+	if err := registry.Register(NewDiceTool()); err == nil {
+		t.Error("expected error registering duplicate tool")
+	}
+
+	definitions := registry.Definitions()
+	if len(definitions) != 1 || definitions[0].Function.Name != "roll_dice" {
+		t.Errorf("unexpected definitions %v", definitions)
+	}
+
+	if _, err := registry.Execute(context.Background(), "missing", "{}"); !errors.Is(err, ErrToolNotFound) {
+		t.Errorf("expected ErrToolNotFound, got %v", err)
+	}
+}
+
+func TestDiceTool(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        string
+		expected    string
+		expectError bool
+	}{
+		{name: "Success", args: `{"count": 2, "sides": 6}`, expected: "Rolled 2d6: 3, 3 (total 6)"},
+		{name: "Invalid JSON", args: `not json`, expectError: true},
+		{name: "Too many dice", args: `{"count": 1000, "sides": 6}`, expectError: true},
+		{name: "Too few sides", args: `{"count": 1, "sides": 1}`, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dice := &DiceTool{roll: func(sides int) int { return 3 }}
+			result, err := dice.Execute(context.Background(), tt.args)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
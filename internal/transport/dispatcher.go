@@ -0,0 +1,142 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"rsandz/bearlawyergo/internal/memory"
+	"rsandz/bearlawyergo/internal/message"
+	"rsandz/bearlawyergo/internal/orchestrator"
+	"sync"
+	"time"
+)
+
+// BotName is the user name the bot's replies are remembered under.
+const BotName = "Bear Lawyer"
+
+// compactTimeout bounds summarizing a conversation in the background.
+const compactTimeout = 2 * time.Minute
+
+// Dispatcher runs requests from any transport through the orchestrator and remembers each conversation,
+// so that transports only translate between their users and requests.
+type Dispatcher struct {
+	orchestrator *orchestrator.Orchestrator
+	// store keeps each conversation with the bot and its running summary.
+	store     memory.Store
+	compactor *memory.Compactor
+	// compacting tracks the conversations being summarized in the background.
+	compacting sync.WaitGroup
+	logger     *slog.Logger
+}
+
+// Creates a new dispatcher. Conversations are summarized with the summarizer as they grow.
+func NewDispatcher(orchestrator *orchestrator.Orchestrator, store memory.Store, summarizer memory.Summarizer, logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		orchestrator: orchestrator,
+		store:        store,
+		compactor:    memory.NewCompactor(store, summarizer, memory.DefaultSummaryThreshold, memory.DefaultSummaryKeep),
+		logger:       logger,
+	}
+}
+
+// Dispatch handles the request and remembers the exchange under the request's conversation.
+// Edited requests are not remembered again, since the exchange with the original message already was.
+// The conversation's summary is loaded onto the request first. The request's history is left to the transport,
+// which may load it with History.
+// If stream is not nil, partial output is written to it while handling and it is closed once handling finishes,
+// so callers should read it concurrently.
+// The conversation is summarized in the background once it grows, so the reply is not held up.
+func (d *Dispatcher) Dispatch(ctx context.Context, req *message.Request, stream *message.Stream) (*message.Response, error) {
+	ctx = orchestrator.WithTraceID(ctx, d.logger)
+	conversationID := req.ConversationID()
+
+	summary, err := d.store.Summary(ctx, conversationID)
+	if err != nil {
+		d.logger.WarnContext(ctx, "Failed to load conversation summary", "error", err, "conversation_id", conversationID)
+	}
+	req.Summary = summary
+
+	resp, err := d.orchestrator.HandleStream(ctx, req, stream)
+	if err != nil {
+		d.logger.InfoContext(ctx, "Error handling message", "error", err, "conversation_id", conversationID)
+		return nil, err
+	}
+
+	if req.Edited {
+		return resp, nil
+	}
+	reply := message.NewMessage(BotName, resp.ResponseMessage.Content, message.BotRole)
+	if err := d.store.Append(ctx, conversationID, req.RequestMessage, *reply); err != nil {
+		d.logger.WarnContext(ctx, "Failed to save conversation", "error", err, "conversation_id", conversationID)
+	}
+	d.compacting.Go(func() {
+		// The request's context ends once the reply is delivered, so summarizing gets its own.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), compactTimeout)
+		defer cancel()
+		if err := d.compactor.Compact(ctx, conversationID); err != nil {
+			d.logger.WarnContext(ctx, "Failed to summarize conversation", "error", err, "conversation_id", conversationID)
+		}
+	})
+	return resp, nil
+}
+
+// Wait waits for conversations being summarized in the background.
+func (d *Dispatcher) Wait() {
+	d.compacting.Wait()
+}
+
+// DispatchStateless handles the request without loading or remembering its conversation,
+// for clients that send the whole conversation with every request.
+// The stream is handled as in Dispatch.
+func (d *Dispatcher) DispatchStateless(ctx context.Context, req *message.Request, stream *message.Stream) (*message.Response, error) {
+	ctx = orchestrator.WithTraceID(ctx, d.logger)
+	resp, err := d.orchestrator.HandleStream(ctx, req, stream)
+	if err != nil {
+		d.logger.InfoContext(ctx, "Error handling message", "error", err, "conversation_id", req.ConversationID())
+		return nil, err
+	}
+	return resp, nil
+}
+
+// DispatchInteraction routes the use of a component back to the handler that added it.
+// Interactions are not remembered as part of the conversation.
+func (d *Dispatcher) DispatchInteraction(ctx context.Context, interaction *message.Interaction) (*message.Response, error) {
+	ctx = orchestrator.WithTraceID(ctx, d.logger)
+	conversationID := interaction.Request.ConversationID()
+
+	summary, err := d.store.Summary(ctx, conversationID)
+	if err != nil {
+		d.logger.WarnContext(ctx, "Failed to load conversation summary", "error", err, "conversation_id", conversationID)
+	}
+	interaction.Request.Summary = summary
+
+	resp, err := d.orchestrator.HandleInteraction(ctx, interaction)
+	if err != nil {
+		d.logger.InfoContext(ctx, "Error handling interaction", "error", err, "conversation_id", conversationID)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// History returns the remembered messages of the conversation, oldest first.
+// Older messages may have been folded into the conversation's summary.
+func (d *Dispatcher) History(ctx context.Context, conversationID string) ([]message.Message, error) {
+	return d.store.Range(ctx, conversationID, memory.Query{})
+}
+
+// Forget clears the conversation's history and summary.
+func (d *Dispatcher) Forget(ctx context.Context, conversationID string) error {
+	return d.store.Clear(ctx, conversationID)
+}
+
+// ErrorReply returns the message shown to a user whose request failed with err.
+func ErrorReply(err error) string {
+	switch {
+	case errors.Is(err, orchestrator.ErrHandlerTimeout):
+		return "Sorry! That took too long to answer. Please try again later."
+	case errors.Is(err, orchestrator.ErrHandlerPanicked):
+		return "Sorry! Something broke while handling your message. Please try again later."
+	default:
+		return "Sorry! Something went wrong. Please try again later."
+	}
+}
@@ -0,0 +1,99 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"rsandz/bearlawyergo/internal/memory"
+	"rsandz/bearlawyergo/internal/message"
+	"rsandz/bearlawyergo/internal/orchestrator"
+)
+
+// slowSummarizer blocks until released.
+type slowSummarizer struct {
+	release chan struct{}
+}
+
+func (s slowSummarizer) Summarize(ctx context.Context, previous string, messages []message.Message) (string, error) {
+	<-s.release
+	return "summary", nil
+}
+
+// newTestDispatcher returns a dispatcher whose only handler answers "Sustained".
+func newTestDispatcher(store memory.Store, summarizer memory.Summarizer) *Dispatcher {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := orchestrator.HandlerFunc(func(ctx context.Context, msg *message.Request, response *message.Response) error {
+		response.ResponseMessage = message.Message{Content: "Sustained", Role: message.BotRole}
+		response.ShouldContinueHandling = false
+		return nil
+	})
+	return NewDispatcher(orchestrator.NewOrchestrator(orchestrator.NewRouter(handler), logger), store, summarizer, logger)
+}
+
+func TestDispatch_Edited(t *testing.T) {
+	ctx := context.Background()
+	summarizer := slowSummarizer{release: make(chan struct{})}
+	close(summarizer.release)
+	dispatcher := newTestDispatcher(memory.NewInMemoryStore(memory.DefaultRetention), summarizer)
+
+	for i, content := range []string{"Objection!", "Objection, hearsay!", "Objection, leading!"} {
+		req := message.NewRequest(*message.NewMessage("alice", content, message.UserRole), nil, "court")
+		req.Edited = i > 0
+		if _, err := dispatcher.Dispatch(ctx, req, nil); err != nil {
+			t.Fatalf("Dispatch() unexpected error = %v", err)
+		}
+	}
+	dispatcher.Wait()
+
+	history, err := dispatcher.History(ctx, "court")
+	if err != nil {
+		t.Fatalf("History() unexpected error = %v", err)
+	}
+	if len(history) != 2 {
+		t.Errorf("expected edits not to be remembered again, got %d messages", len(history))
+	}
+}
+
+func TestDispatch_SummarizesInBackground(t *testing.T) {
+	store := memory.NewInMemoryStore(memory.DefaultRetention)
+	summarizer := slowSummarizer{release: make(chan struct{})}
+	dispatcher := newTestDispatcher(store, summarizer)
+
+	// Fill the conversation past the summary threshold so the next exchange is summarized.
+	for range memory.DefaultSummaryThreshold {
+		if err := store.Append(context.Background(), "court", message.Message{Content: "Objection!"}); err != nil {
+			t.Fatalf("Append() unexpected error = %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan *message.Response)
+	go func() {
+		req := message.NewRequest(*message.NewMessage("alice", "Objection!", message.UserRole), nil, "court")
+		resp, err := dispatcher.Dispatch(ctx, req, nil)
+		if err != nil {
+			t.Errorf("Dispatch() unexpected error = %v", err)
+		}
+		done <- resp
+	}()
+
+	select {
+	case resp := <-done:
+		if resp == nil || resp.ResponseMessage.Content != "Sustained" {
+			t.Errorf("expected reply, got %+v", resp)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected reply before the conversation is summarized")
+	}
+
+	// Summarizing outlives the request.
+	cancel()
+	close(summarizer.release)
+	dispatcher.Wait()
+	if summary, _ := store.Summary(context.Background(), "court"); summary != "summary" {
+		t.Errorf("expected conversation to be summarized in the background, got %q", summary)
+	}
+}
@@ -0,0 +1,61 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"rsandz/bearlawyergo/internal/message"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Transport is a frontend through which users talk to the bot, such as Discord or the command line.
+type Transport interface {
+	// Name identifies the transport in logs.
+	Name() string
+	// Start serves users until ctx is cancelled or the transport finishes by itself.
+	Start(ctx context.Context) error
+	// Stop releases the transport's resources once Start has returned.
+	Stop() error
+	// Send writes a message to the conversation without a request, e.g. a reminder.
+	Send(ctx context.Context, conversationID string, msg message.Message) error
+	// Capabilities describes what the transport can render.
+	Capabilities() message.Capabilities
+}
+
+// Run starts the transports and waits until ctx is cancelled or one of them finishes,
+// then stops all of them. Returns the errors of transports that failed.
+func Run(ctx context.Context, logger *slog.Logger, transports ...Transport) error {
+	if len(transports) == 0 {
+		return errors.New("no transports to run")
+	}
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	for _, t := range transports {
+		group.Go(func() error {
+			logger.Info("Starting transport", "transport", t.Name())
+			if err := t.Start(groupCtx); err != nil {
+				return fmt.Errorf("transport %s failed: %w", t.Name(), err)
+			}
+			logger.Info("Transport finished", "transport", t.Name())
+			// One transport finishing, such as the command line exiting, shuts down the others.
+			return errStopped
+		})
+	}
+	err := group.Wait()
+	if errors.Is(err, errStopped) {
+		err = nil
+	}
+
+	for _, t := range transports {
+		if stopErr := t.Stop(); stopErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to stop transport %s: %w", t.Name(), stopErr))
+		}
+	}
+	return err
+}
+
+// errStopped cancels the remaining transports once one finishes.
+var errStopped = errors.New("transport stopped")
@@ -0,0 +1,84 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"rsandz/bearlawyergo/internal/message"
+)
+
+// fakeTransport runs until ctx is cancelled, or finishes straight away with err if finish is set.
+type fakeTransport struct {
+	name    string
+	finish  bool
+	err     error
+	stopped bool
+}
+
+func (f *fakeTransport) Name() string { return f.name }
+
+func (f *fakeTransport) Start(ctx context.Context) error {
+	if f.finish {
+		return f.err
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (f *fakeTransport) Stop() error {
+	f.stopped = true
+	return nil
+}
+
+func (f *fakeTransport) Send(ctx context.Context, conversationID string, msg message.Message) error {
+	return nil
+}
+
+func (f *fakeTransport) Capabilities() message.Capabilities { return message.Capabilities{} }
+
+func TestRun(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	failure := errors.New("connection lost")
+
+	tests := []struct {
+		name    string
+		finish  *fakeTransport
+		wantErr error
+	}{
+		{name: "finished transport stops the others", finish: &fakeTransport{name: "cli", finish: true}},
+		{name: "failed transport stops the others", finish: &fakeTransport{name: "discord", finish: true, err: failure}, wantErr: failure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			running := &fakeTransport{name: "http"}
+			err := Run(context.Background(), logger, running, tt.finish)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("Run() error = %v, want %v", err, tt.wantErr)
+			}
+			if !running.stopped || !tt.finish.stopped {
+				t.Errorf("stopped = %v, %v, want all transports stopped", running.stopped, tt.finish.stopped)
+			}
+		})
+	}
+
+	t.Run("cancelled context stops all transports", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		running := &fakeTransport{name: "discord"}
+		if err := Run(ctx, logger, running); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if !running.stopped {
+			t.Error("transport was not stopped")
+		}
+	})
+
+	t.Run("no transports", func(t *testing.T) {
+		if err := Run(context.Background(), logger); err == nil {
+			t.Error("Run() error = nil, want an error")
+		}
+	})
+}